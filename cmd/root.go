@@ -1,14 +1,21 @@
 package root
 
 import (
+	"errors"
 	"fmt"
 	"os"
 	"os/user"
+	"path/filepath"
 
+	"github.com/AhmedThresh/not-even-a-compiler/pkg/interpreter"
 	"github.com/AhmedThresh/not-even-a-compiler/pkg/repl"
 )
 
 func Run() {
+	if len(os.Args) > 1 {
+		os.Exit(runFile(os.Args[1]))
+	}
+
 	user, err := user.Current()
 	if err != nil {
 		panic(err)
@@ -18,3 +25,28 @@ func Run() {
 	fmt.Printf("Feel free to type in commands\n")
 	repl.Start(os.Stdin, os.Stdout)
 }
+
+// runFile runs the script at path and returns the exit code, runtime errors
+// are reported on stderr with their traceback
+func runFile(path string) int {
+	_, err := interpreter.New(interpreter.WithModulePath(modulePath()...)).RunFile(path)
+	if err == nil {
+		return 0
+	}
+
+	var runtimeErr *interpreter.RuntimeError
+	if errors.As(err, &runtimeErr) {
+		fmt.Fprintln(os.Stderr, runtimeErr.Traceback())
+	} else {
+		fmt.Fprintln(os.Stderr, err)
+	}
+	return 1
+}
+
+// modulePath returns the module search path set with MONKEY_PATH
+func modulePath() []string {
+	if value := os.Getenv("MONKEY_PATH"); value != "" {
+		return filepath.SplitList(value)
+	}
+	return nil
+}
@@ -22,10 +22,18 @@ type Expression interface {
 	expressionNode()
 }
 
+// Pattern is the target of a binding, a plain identifier or a pattern that
+// destructures the value
+type Pattern interface {
+	Node
+	patternNode()
+}
+
 type LetStatement struct {
-	Token token.Token // The token.LET token
-	Name  *Identifier
-	Value Expression
+	Token   token.Token // The token.LET token
+	Name    *Identifier
+	Pattern Pattern // Set instead of Name when destructuring
+	Value   Expression
 }
 
 func (l *LetStatement) statementNode() {}
@@ -37,7 +45,11 @@ func (l *LetStatement) String() string {
 	var buffer bytes.Buffer
 
 	buffer.WriteString(l.Token.Literal + " ")
-	buffer.WriteString(l.Name.String() + " = ")
+	if l.Pattern != nil {
+		buffer.WriteString(l.Pattern.String() + " = ")
+	} else {
+		buffer.WriteString(l.Name.String() + " = ")
+	}
 
 	if l.Value != nil {
 		buffer.WriteString(l.Value.String())
@@ -69,6 +81,34 @@ func (r *ReturnStatement) String() string {
 	return buffer.String()
 }
 
+type ThrowStatement struct {
+	Token token.Token // The token.THROW token
+	Value Expression
+}
+
+func (t *ThrowStatement) statementNode() {}
+func (t *ThrowStatement) TokenLiteral() string {
+	return t.Token.Literal
+}
+func (t *ThrowStatement) String() string {
+	return t.Token.Literal + " " + t.Value.String() + ";"
+}
+
+// ExportStatement is a let statement whose bindings are exported by the
+// module it appears in
+type ExportStatement struct {
+	Token     token.Token // The token.EXPORT token
+	Statement *LetStatement
+}
+
+func (e *ExportStatement) statementNode() {}
+func (e *ExportStatement) TokenLiteral() string {
+	return e.Token.Literal
+}
+func (e *ExportStatement) String() string {
+	return e.Token.Literal + " " + e.Statement.String()
+}
+
 type ExpressionStatement struct {
 	Token      token.Token // The first token of the statement
 	Expression Expression
@@ -197,6 +237,81 @@ func (i *IfExpression) String() string {
 	return out.String()
 }
 
+// TryExpression evaluates Block and, when it fails with an error, Catch with
+// the error bound to CatchParam. Finally runs in every case.
+type TryExpression struct {
+	Token      token.Token // The try token
+	Block      *BlockStatement
+	CatchParam *Identifier     // nil for catch blocks without a parameter
+	Catch      *BlockStatement // nil without a catch block
+	Finally    *BlockStatement // nil without a finally block
+}
+
+func (t *TryExpression) expressionNode() {}
+func (t *TryExpression) TokenLiteral() string {
+	return t.Token.Literal
+}
+func (t *TryExpression) String() string {
+	var out bytes.Buffer
+	out.WriteString("try {")
+	out.WriteString(t.Block.String())
+	out.WriteString("}")
+
+	if t.Catch != nil {
+		out.WriteString(" catch ")
+		if t.CatchParam != nil {
+			out.WriteString("(" + t.CatchParam.String() + ") ")
+		}
+		out.WriteString("{")
+		out.WriteString(t.Catch.String())
+		out.WriteString("}")
+	}
+
+	if t.Finally != nil {
+		out.WriteString(" finally {")
+		out.WriteString(t.Finally.String())
+		out.WriteString("}")
+	}
+	return out.String()
+}
+
+// ImportExpression evaluates to the module found at Path
+type ImportExpression struct {
+	Token token.Token // The token.IMPORT token
+	Path  string
+}
+
+func (i *ImportExpression) expressionNode() {}
+func (i *ImportExpression) TokenLiteral() string {
+	return i.Token.Literal
+}
+func (i *ImportExpression) String() string {
+	return i.Token.Literal + ` "` + i.Path + `"`
+}
+
+type ConditionalExpression struct {
+	Token       token.Token // The ? token
+	Condition   Expression
+	Consequence Expression
+	Alternative Expression
+}
+
+func (c *ConditionalExpression) expressionNode() {}
+func (c *ConditionalExpression) TokenLiteral() string {
+	return c.Token.Literal
+}
+func (c *ConditionalExpression) String() string {
+	var out bytes.Buffer
+	out.WriteString("(")
+	out.WriteString(c.Condition.String())
+	out.WriteString(" ? ")
+	out.WriteString(c.Consequence.String())
+	out.WriteString(" : ")
+	out.WriteString(c.Alternative.String())
+	out.WriteString(")")
+	return out.String()
+}
+
 type BlockStatement struct {
 	Token      token.Token
 	Statements []Statement
@@ -215,9 +330,37 @@ func (b *BlockStatement) String() string {
 	return out.String()
 }
 
+// Parameter is a function parameter. It is filled with Default, evaluated at
+// call time, when no argument is given for it. A Rest parameter collects the
+// remaining arguments into an array.
+type Parameter struct {
+	Name    *Identifier
+	Pattern Pattern    // Set instead of Name when the argument is destructured
+	Default Expression // nil when the parameter has no default
+	Rest    bool
+}
+
+func (p *Parameter) String() string {
+	if p.Rest {
+		return "..." + p.Name.String()
+	}
+
+	target := ""
+	if p.Pattern != nil {
+		target = p.Pattern.String()
+	} else {
+		target = p.Name.String()
+	}
+
+	if p.Default != nil {
+		return target + " = " + p.Default.String()
+	}
+	return target
+}
+
 type FunctionLiteral struct {
 	Token      token.Token // The fn token
-	Parameters []*Identifier
+	Parameters []*Parameter
 	Body       *BlockStatement
 }
 
@@ -266,6 +409,35 @@ func (ce *CallExpression) String() string {
 	return out.String()
 }
 
+// SpreadExpression expands an array into separate arguments, as in f(...xs)
+type SpreadExpression struct {
+	Token token.Token // The token.ELLIPSIS token
+	Value Expression
+}
+
+func (s *SpreadExpression) expressionNode() {}
+func (s *SpreadExpression) TokenLiteral() string {
+	return s.Token.Literal
+}
+func (s *SpreadExpression) String() string {
+	return "..." + s.Value.String()
+}
+
+// NamedArgument passes an argument by parameter name, as in f(b = 2)
+type NamedArgument struct {
+	Token token.Token // The token.IDENT token
+	Name  *Identifier
+	Value Expression
+}
+
+func (n *NamedArgument) expressionNode() {}
+func (n *NamedArgument) TokenLiteral() string {
+	return n.Token.Literal
+}
+func (n *NamedArgument) String() string {
+	return n.Name.String() + " = " + n.Value.String()
+}
+
 type Array struct {
 	Token    token.Token // The [ token
 	Elements []Expression
@@ -296,6 +468,7 @@ type Identifier struct {
 }
 
 func (i *Identifier) expressionNode() {}
+func (i *Identifier) patternNode()    {}
 func (i *Identifier) TokenLiteral() string {
 	return i.Token.Literal
 }
@@ -323,9 +496,41 @@ func (i *IndexExpression) String() string {
 	return out.String()
 }
 
+type SliceExpression struct {
+	Token token.Token // The token.LBRACKET token
+	Left  Expression
+	Start Expression // nil when omitted, as in a[:2]
+	End   Expression // nil when omitted, as in a[2:]
+}
+
+func (s *SliceExpression) expressionNode() {}
+func (s *SliceExpression) TokenLiteral() string {
+	return s.Token.Literal
+}
+func (s *SliceExpression) String() string {
+	var out bytes.Buffer
+	out.WriteString("(")
+	out.WriteString(s.Left.String())
+	out.WriteString("[")
+	if s.Start != nil {
+		out.WriteString(s.Start.String())
+	}
+	out.WriteString(":")
+	if s.End != nil {
+		out.WriteString(s.End.String())
+	}
+	out.WriteString("])")
+	return out.String()
+}
+
+type HashLiteralPair struct {
+	Key   Expression
+	Value Expression
+}
+
 type HashLiteral struct {
-	Token token.Token // The { token
-	Pairs map[Expression]Expression
+	Token token.Token       // The { token
+	Pairs []HashLiteralPair // In source order
 }
 
 func (h *HashLiteral) expressionNode() {}
@@ -335,15 +540,147 @@ func (h *HashLiteral) TokenLiteral() string {
 func (h *HashLiteral) String() string {
 	var out bytes.Buffer
 	pairs := []string{}
-	for key, value := range h.Pairs {
-		pairs = append(pairs, key.String()+":"+value.String())
+	for _, pair := range h.Pairs {
+		pairs = append(pairs, pair.Key.String()+":"+pair.Value.String())
+	}
+	out.WriteString("{")
+	out.WriteString(strings.Join(pairs, ", "))
+	out.WriteString("}")
+	return out.String()
+}
+
+// ArrayPattern destructures an array, as in let [a, b, ...rest] = xs. Open
+// patterns, ending in ... or ...rest, accept arrays with extra elements.
+type ArrayPattern struct {
+	Token    token.Token // The [ token
+	Elements []Pattern
+	Open     bool
+	Rest     *Identifier // nil unless the extra elements are bound
+}
+
+func (a *ArrayPattern) patternNode() {}
+func (a *ArrayPattern) TokenLiteral() string {
+	return a.Token.Literal
+}
+func (a *ArrayPattern) String() string {
+	var out bytes.Buffer
+	elements := []string{}
+	for _, el := range a.Elements {
+		elements = append(elements, el.String())
+	}
+
+	if a.Rest != nil {
+		elements = append(elements, "..."+a.Rest.String())
+	} else if a.Open {
+		elements = append(elements, "...")
+	}
+
+	out.WriteString("[")
+	out.WriteString(strings.Join(elements, ", "))
+	out.WriteString("]")
+	return out.String()
+}
+
+type HashPatternPair struct {
+	Key   string
+	Value Pattern
+}
+
+// HashPattern destructures a hash by string keys, as in let {name, age} = p
+// or let {name: n, ...others} = p. Destructuring ignores the keys a pattern
+// doesn't name, a match arm only does so for open patterns ending in ... or
+// ...rest.
+type HashPattern struct {
+	Token token.Token // The { token
+	Pairs []HashPatternPair
+	Open  bool
+	Rest  *Identifier // nil unless the remaining pairs are bound
+}
+
+func (h *HashPattern) patternNode() {}
+func (h *HashPattern) TokenLiteral() string {
+	return h.Token.Literal
+}
+func (h *HashPattern) String() string {
+	var out bytes.Buffer
+	pairs := []string{}
+	for _, pair := range h.Pairs {
+		if ident, ok := pair.Value.(*Identifier); ok && ident.Value == pair.Key {
+			pairs = append(pairs, pair.Key)
+		} else {
+			pairs = append(pairs, pair.Key+": "+pair.Value.String())
+		}
 	}
+
+	if h.Rest != nil {
+		pairs = append(pairs, "..."+h.Rest.String())
+	} else if h.Open {
+		pairs = append(pairs, "...")
+	}
+
 	out.WriteString("{")
 	out.WriteString(strings.Join(pairs, ", "))
 	out.WriteString("}")
 	return out.String()
 }
 
+// LiteralPattern matches values equal to a literal, as in match (x) { 1 => ... }
+type LiteralPattern struct {
+	Token token.Token // The first token of the literal
+	Value Expression
+}
+
+func (l *LiteralPattern) patternNode() {}
+func (l *LiteralPattern) TokenLiteral() string {
+	return l.Token.Literal
+}
+func (l *LiteralPattern) String() string {
+	if str, ok := l.Value.(*StringLiteral); ok {
+		return `"` + str.Value + `"`
+	}
+	return l.Value.String()
+}
+
+type MatchArm struct {
+	Pattern Pattern
+	Guard   Expression // nil without an if guard
+	Body    Expression
+}
+
+func (m *MatchArm) String() string {
+	if m.Guard != nil {
+		return m.Pattern.String() + " if " + m.Guard.String() + " => " + m.Body.String()
+	}
+	return m.Pattern.String() + " => " + m.Body.String()
+}
+
+// MatchExpression evaluates the body of the first arm whose pattern matches
+// Value and whose guard holds
+type MatchExpression struct {
+	Token token.Token // The match token
+	Value Expression
+	Arms  []*MatchArm
+}
+
+func (m *MatchExpression) expressionNode() {}
+func (m *MatchExpression) TokenLiteral() string {
+	return m.Token.Literal
+}
+func (m *MatchExpression) String() string {
+	var out bytes.Buffer
+	arms := []string{}
+	for _, arm := range m.Arms {
+		arms = append(arms, arm.String())
+	}
+
+	out.WriteString("match (")
+	out.WriteString(m.Value.String())
+	out.WriteString(") { ")
+	out.WriteString(strings.Join(arms, ", "))
+	out.WriteString(" }")
+	return out.String()
+}
+
 type Program struct {
 	Statements []Statement
 }
@@ -25,3 +25,26 @@ func TestString(t *testing.T) {
 		t.Errorf("program.String() wrong. got=%q", program.String())
 	}
 }
+
+func TestHashLiteralString(t *testing.T) {
+	key := func(literal string) Expression {
+		return &StringLiteral{Token: token.Token{Type: token.STRING, Literal: literal}, Value: literal}
+	}
+	value := func(literal string) Expression {
+		return &Identifier{Token: token.Token{Type: token.IDENT, Literal: literal}, Value: literal}
+	}
+
+	hash := &HashLiteral{
+		Token: token.Token{Type: token.LBRACE, Literal: "{"},
+		Pairs: []HashLiteralPair{
+			{Key: key("z"), Value: value("one")},
+			{Key: key("a"), Value: value("two")},
+			{Key: key("m"), Value: value("three")},
+		},
+	}
+
+	expected := "{z:one, a:two, m:three}"
+	if hash.String() != expected {
+		t.Fatalf("hash.String() wrong. want=%q, got=%q", expected, hash.String())
+	}
+}
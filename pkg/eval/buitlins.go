@@ -2,22 +2,58 @@ package eval
 
 import (
 	"fmt"
+	"io"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode/utf8"
 
 	"github.com/AhmedThresh/not-even-a-compiler/pkg/object"
 )
 
+// Builtins returns a copy of the default builtin functions that can be
+// extended or trimmed and then installed through object.Runtime.Builtins.
+// Each builtin is copied too, so changing one does not affect the defaults.
+func Builtins() map[string]*object.Builtin {
+	res := make(map[string]*object.Builtin, len(builtins))
+	for name, fn := range builtins {
+		copied := *fn
+		res[name] = &copied
+	}
+	return res
+}
+
+func init() {
+	for name, fn := range builtins {
+		fn.Name = name
+	}
+}
+
+func lookupBuiltin(env *object.Environment, name string) (*object.Builtin, bool) {
+	available := builtins
+	if env.Runtime().Builtins != nil {
+		available = env.Runtime().Builtins
+	}
+
+	fn, ok := available[name]
+	return fn, ok
+}
+
 var builtins = map[string]*object.Builtin{
 	"len": {
-		Fn: func(args ...object.Object) object.Object {
+		Fn: func(ctx *object.CallContext, args ...object.Object) object.Object {
 			if len(args) != 1 {
 				return newError("wrong number of arguments. got=%d, want=1", len(args))
 			}
 
-			switch arg := args[0].(type) {
+			switch arg := exportsOf(args[0]).(type) {
 			case *object.String:
-				return &object.Integer{Value: int64(len(arg.Value))}
+				return &object.Integer{Value: int64(utf8.RuneCountInString(arg.Value))}
 			case *object.Array:
 				return &object.Integer{Value: int64(len(arg.Elements))}
+			case *object.Hash:
+				return &object.Integer{Value: int64(arg.Len())}
 			default:
 				return newError("argument to `len` not supported, got %s", args[0].Type())
 			}
@@ -25,7 +61,7 @@ var builtins = map[string]*object.Builtin{
 	},
 
 	"first": {
-		Fn: func(args ...object.Object) object.Object {
+		Fn: func(ctx *object.CallContext, args ...object.Object) object.Object {
 			if len(args) != 1 {
 				return newError("wrong number of arguments. got=%d, want=1", len(args))
 			}
@@ -43,7 +79,7 @@ var builtins = map[string]*object.Builtin{
 	},
 
 	"last": {
-		Fn: func(args ...object.Object) object.Object {
+		Fn: func(ctx *object.CallContext, args ...object.Object) object.Object {
 			if len(args) != 1 {
 				return newError("wrong number of arguments. got=%d, want=1", len(args))
 			}
@@ -61,7 +97,7 @@ var builtins = map[string]*object.Builtin{
 	},
 
 	"rest": {
-		Fn: func(args ...object.Object) object.Object {
+		Fn: func(ctx *object.CallContext, args ...object.Object) object.Object {
 			if len(args) != 1 {
 				return newError("wrong number of arguments. got=%d, want=1", len(args))
 			}
@@ -71,7 +107,7 @@ var builtins = map[string]*object.Builtin{
 				if len(arg.Elements) < 1 {
 					return NULL
 				}
-				return &object.Array{Elements: arg.Elements[1:len(arg.Elements)]}
+				return sliceArray(arg, 1, len(arg.Elements))
 			default:
 				return newError("argument to `rest` not supported, got %s", args[0].Type())
 			}
@@ -79,7 +115,7 @@ var builtins = map[string]*object.Builtin{
 	},
 
 	"push": {
-		Fn: func(args ...object.Object) object.Object {
+		Fn: func(ctx *object.CallContext, args ...object.Object) object.Object {
 			if len(args) != 2 {
 				return newError("wrong number of arguments. got=%d, want=2", len(args))
 			}
@@ -88,18 +124,914 @@ var builtins = map[string]*object.Builtin{
 				return newError("argument to `push` must be ARRAY, got %s", args[0].Type())
 			}
 
+			// push grows the array in place, so the result is not tracked
+			// as a new object and the element is accounted for here
+			if err := allocate(ctx.Runtime, valueSize); err != nil {
+				return err
+			}
+
 			arr := args[0].(*object.Array)
 			arr.Elements = append(arr.Elements, args[1])
 			return arr
 		},
 	},
 
+	"keys": {
+		Fn: func(ctx *object.CallContext, args ...object.Object) object.Object {
+			if len(args) != 1 {
+				return newError("wrong number of arguments. got=%d, want=1", len(args))
+			}
+
+			hash, ok := exportsOf(args[0]).(*object.Hash)
+			if !ok {
+				return newError("argument to `keys` must be HASH, got %s", args[0].Type())
+			}
+
+			elements := []object.Object{}
+			for _, pair := range hash.Pairs() {
+				if err := allocate(ctx.Runtime, sizeOfKeyCopy(pair.Key)); err != nil {
+					return err
+				}
+				elements = append(elements, object.CopyKey(pair.Key))
+			}
+			return &object.Array{Elements: elements}
+		},
+	},
+
+	"values": {
+		Fn: func(ctx *object.CallContext, args ...object.Object) object.Object {
+			if len(args) != 1 {
+				return newError("wrong number of arguments. got=%d, want=1", len(args))
+			}
+
+			hash, ok := exportsOf(args[0]).(*object.Hash)
+			if !ok {
+				return newError("argument to `values` must be HASH, got %s", args[0].Type())
+			}
+
+			elements := []object.Object{}
+			for _, pair := range hash.Pairs() {
+				elements = append(elements, pair.Value)
+			}
+			return &object.Array{Elements: elements}
+		},
+	},
+
+	"entries": {
+		Fn: func(ctx *object.CallContext, args ...object.Object) object.Object {
+			if len(args) != 1 {
+				return newError("wrong number of arguments. got=%d, want=1", len(args))
+			}
+
+			hash, ok := exportsOf(args[0]).(*object.Hash)
+			if !ok {
+				return newError("argument to `entries` must be HASH, got %s", args[0].Type())
+			}
+
+			elements := []object.Object{}
+			for _, pair := range hash.Pairs() {
+				entry := &object.Array{Elements: []object.Object{object.CopyKey(pair.Key), pair.Value}}
+				if err := allocate(ctx.Runtime, sizeOf(entry)+sizeOfKeyCopy(pair.Key)); err != nil {
+					return err
+				}
+				elements = append(elements, entry)
+			}
+			return &object.Array{Elements: elements}
+		},
+	},
+
+	"has": {
+		Fn: func(ctx *object.CallContext, args ...object.Object) object.Object {
+			if len(args) != 2 {
+				return newError("wrong number of arguments. got=%d, want=2", len(args))
+			}
+
+			hash, ok := exportsOf(args[0]).(*object.Hash)
+			if !ok {
+				return newError("argument to `has` must be HASH, got %s", args[0].Type())
+			}
+
+			if !object.IsHashable(args[1]) {
+				return newError("unusable as hash key: %s", args[1].Type())
+			}
+
+			_, found := hash.Get(args[1])
+			return nativeBoolToBooleanObject(found)
+		},
+	},
+
+	"delete": {
+		Fn: func(ctx *object.CallContext, args ...object.Object) object.Object {
+			if len(args) != 2 {
+				return newError("wrong number of arguments. got=%d, want=2", len(args))
+			}
+
+			hash, ok := exportsOf(args[0]).(*object.Hash)
+			if !ok {
+				return newError("argument to `delete` must be HASH, got %s", args[0].Type())
+			}
+
+			if !object.IsHashable(args[1]) {
+				return newError("unusable as hash key: %s", args[1].Type())
+			}
+
+			res := object.NewHash()
+			for _, pair := range hash.Pairs() {
+				if !pair.Key.Equals(args[1]) {
+					res.Set(pair.Key, pair.Value)
+				}
+			}
+			return res
+		},
+	},
+
+	"merge": {
+		Fn: func(ctx *object.CallContext, args ...object.Object) object.Object {
+			if len(args) != 2 {
+				return newError("wrong number of arguments. got=%d, want=2", len(args))
+			}
+
+			res := object.NewHash()
+			for _, arg := range args {
+				hash, ok := exportsOf(arg).(*object.Hash)
+				if !ok {
+					return newError("argument to `merge` must be HASH, got %s", arg.Type())
+				}
+
+				for _, pair := range hash.Pairs() {
+					res.Set(pair.Key, pair.Value)
+				}
+			}
+			return res
+		},
+	},
+
+	"map": {
+		Fn: func(ctx *object.CallContext, args ...object.Object) object.Object {
+			if len(args) != 2 {
+				return newError("wrong number of arguments. got=%d, want=2", len(args))
+			}
+
+			if args[0].Type() != object.ARRAY {
+				return newError("argument to `map` must be ARRAY, got %s", args[0].Type())
+			}
+
+			if !isCallable(args[1]) {
+				return newError("argument to `map` must be callable, got %s", args[1].Type())
+			}
+
+			arr := args[0].(*object.Array)
+			elements := make([]object.Object, 0, len(arr.Elements))
+			for _, el := range arr.Elements {
+				res := ctx.Apply(args[1], el)
+				if isError(res) {
+					return res
+				}
+				elements = append(elements, res)
+			}
+			return &object.Array{Elements: elements}
+		},
+	},
+
+	"filter": {
+		Fn: func(ctx *object.CallContext, args ...object.Object) object.Object {
+			if len(args) != 2 {
+				return newError("wrong number of arguments. got=%d, want=2", len(args))
+			}
+
+			if args[0].Type() != object.ARRAY {
+				return newError("argument to `filter` must be ARRAY, got %s", args[0].Type())
+			}
+
+			if !isCallable(args[1]) {
+				return newError("argument to `filter` must be callable, got %s", args[1].Type())
+			}
+
+			elements := []object.Object{}
+			for _, el := range args[0].(*object.Array).Elements {
+				res := ctx.Apply(args[1], el)
+				if isError(res) {
+					return res
+				}
+				if isTruthy(res) {
+					elements = append(elements, el)
+				}
+			}
+			return &object.Array{Elements: elements}
+		},
+	},
+
+	"reduce": {
+		Fn: func(ctx *object.CallContext, args ...object.Object) object.Object {
+			if len(args) != 3 {
+				return newError("wrong number of arguments. got=%d, want=3", len(args))
+			}
+
+			if args[0].Type() != object.ARRAY {
+				return newError("argument to `reduce` must be ARRAY, got %s", args[0].Type())
+			}
+
+			if !isCallable(args[1]) {
+				return newError("argument to `reduce` must be callable, got %s", args[1].Type())
+			}
+
+			acc := args[2]
+			for _, el := range args[0].(*object.Array).Elements {
+				acc = ctx.Apply(args[1], acc, el)
+				if isError(acc) {
+					return acc
+				}
+			}
+			return acc
+		},
+	},
+
+	"sort": {
+		Fn: func(ctx *object.CallContext, args ...object.Object) object.Object {
+			if len(args) != 1 && len(args) != 2 {
+				return newError("wrong number of arguments. got=%d, want=1 or 2", len(args))
+			}
+
+			if args[0].Type() != object.ARRAY {
+				return newError("argument to `sort` must be ARRAY, got %s", args[0].Type())
+			}
+
+			if len(args) == 2 && !isCallable(args[1]) {
+				return newError("argument to `sort` must be callable, got %s", args[1].Type())
+			}
+
+			arr := args[0].(*object.Array)
+			elements := make([]object.Object, len(arr.Elements))
+			copy(elements, arr.Elements)
+
+			// the comparator cannot abort the sort, so the first error is
+			// kept and every later comparison is skipped
+			var err object.Object
+			sort.SliceStable(elements, func(i, j int) bool {
+				if err != nil {
+					return false
+				}
+
+				if len(args) == 1 {
+					less, e := lessThan(elements[i], elements[j])
+					if e != nil {
+						err = e
+					}
+					return less
+				}
+
+				res := ctx.Apply(args[1], elements[i], elements[j])
+				if isError(res) {
+					err = res
+					return false
+				}
+				less, ok := res.(*object.Boolean)
+				if !ok {
+					err = newError("comparator passed to `sort` must return BOOLEAN, got %s", res.Type())
+					return false
+				}
+				return less.Value
+			})
+
+			if err != nil {
+				return err
+			}
+			return &object.Array{Elements: elements}
+		},
+	},
+
+	"zip": {
+		Fn: func(ctx *object.CallContext, args ...object.Object) object.Object {
+			if len(args) < 1 {
+				return newError("wrong number of arguments. got=%d, want at least 1", len(args))
+			}
+
+			length := -1
+			for _, arg := range args {
+				if arg.Type() != object.ARRAY {
+					return newError("argument to `zip` must be ARRAY, got %s", arg.Type())
+				}
+
+				if n := len(arg.(*object.Array).Elements); length == -1 || n < length {
+					length = n
+				}
+			}
+
+			elements := make([]object.Object, length)
+			for i := range elements {
+				tuple := make([]object.Object, len(args))
+				for j, arg := range args {
+					tuple[j] = arg.(*object.Array).Elements[i]
+				}
+				elements[i] = &object.Array{Elements: tuple}
+				if err := allocate(ctx.Runtime, sizeOf(elements[i])); err != nil {
+					return err
+				}
+			}
+			return &object.Array{Elements: elements}
+		},
+	},
+
+	"split": {
+		Fn: func(ctx *object.CallContext, args ...object.Object) object.Object {
+			if len(args) != 2 {
+				return newError("wrong number of arguments. got=%d, want=2", len(args))
+			}
+
+			for _, arg := range args {
+				if arg.Type() != object.STRING {
+					return newError("argument to `split` must be STRING, got %s", arg.Type())
+				}
+			}
+
+			parts := strings.Split(args[0].(*object.String).Value, args[1].(*object.String).Value)
+			elements := make([]object.Object, len(parts))
+			for i, part := range parts {
+				elements[i] = &object.String{Value: part}
+			}
+			res := &object.Array{Elements: elements}
+			if err := allocateContents(ctx.Runtime, res); err != nil {
+				return err
+			}
+			return res
+		},
+	},
+
+	"join": {
+		Fn: func(ctx *object.CallContext, args ...object.Object) object.Object {
+			if len(args) != 2 {
+				return newError("wrong number of arguments. got=%d, want=2", len(args))
+			}
+
+			if args[0].Type() != object.ARRAY {
+				return newError("argument to `join` must be ARRAY, got %s", args[0].Type())
+			}
+
+			if args[1].Type() != object.STRING {
+				return newError("argument to `join` must be STRING, got %s", args[1].Type())
+			}
+
+			elements := args[0].(*object.Array).Elements
+			parts := make([]string, len(elements))
+			for i, el := range elements {
+				str, ok := el.(*object.String)
+				if !ok {
+					return newError("argument to `join` must be ARRAY of STRING, got %s element", el.Type())
+				}
+				parts[i] = str.Value
+			}
+			return &object.String{Value: strings.Join(parts, args[1].(*object.String).Value)}
+		},
+	},
+
+	"trim": {
+		Fn: func(ctx *object.CallContext, args ...object.Object) object.Object {
+			if len(args) != 1 {
+				return newError("wrong number of arguments. got=%d, want=1", len(args))
+			}
+
+			if args[0].Type() != object.STRING {
+				return newError("argument to `trim` must be STRING, got %s", args[0].Type())
+			}
+
+			return &object.String{Value: strings.TrimSpace(args[0].(*object.String).Value)}
+		},
+	},
+
+	"upper": {
+		Fn: func(ctx *object.CallContext, args ...object.Object) object.Object {
+			if len(args) != 1 {
+				return newError("wrong number of arguments. got=%d, want=1", len(args))
+			}
+
+			if args[0].Type() != object.STRING {
+				return newError("argument to `upper` must be STRING, got %s", args[0].Type())
+			}
+
+			return &object.String{Value: strings.ToUpper(args[0].(*object.String).Value)}
+		},
+	},
+
+	"lower": {
+		Fn: func(ctx *object.CallContext, args ...object.Object) object.Object {
+			if len(args) != 1 {
+				return newError("wrong number of arguments. got=%d, want=1", len(args))
+			}
+
+			if args[0].Type() != object.STRING {
+				return newError("argument to `lower` must be STRING, got %s", args[0].Type())
+			}
+
+			return &object.String{Value: strings.ToLower(args[0].(*object.String).Value)}
+		},
+	},
+
+	"contains": {
+		Fn: func(ctx *object.CallContext, args ...object.Object) object.Object {
+			if len(args) != 2 {
+				return newError("wrong number of arguments. got=%d, want=2", len(args))
+			}
+
+			for _, arg := range args {
+				if arg.Type() != object.STRING {
+					return newError("argument to `contains` must be STRING, got %s", arg.Type())
+				}
+			}
+
+			return nativeBoolToBooleanObject(strings.Contains(args[0].(*object.String).Value, args[1].(*object.String).Value))
+		},
+	},
+
+	"starts_with": {
+		Fn: func(ctx *object.CallContext, args ...object.Object) object.Object {
+			if len(args) != 2 {
+				return newError("wrong number of arguments. got=%d, want=2", len(args))
+			}
+
+			for _, arg := range args {
+				if arg.Type() != object.STRING {
+					return newError("argument to `starts_with` must be STRING, got %s", arg.Type())
+				}
+			}
+
+			return nativeBoolToBooleanObject(strings.HasPrefix(args[0].(*object.String).Value, args[1].(*object.String).Value))
+		},
+	},
+
+	"ends_with": {
+		Fn: func(ctx *object.CallContext, args ...object.Object) object.Object {
+			if len(args) != 2 {
+				return newError("wrong number of arguments. got=%d, want=2", len(args))
+			}
+
+			for _, arg := range args {
+				if arg.Type() != object.STRING {
+					return newError("argument to `ends_with` must be STRING, got %s", arg.Type())
+				}
+			}
+
+			return nativeBoolToBooleanObject(strings.HasSuffix(args[0].(*object.String).Value, args[1].(*object.String).Value))
+		},
+	},
+
+	"replace": {
+		Fn: func(ctx *object.CallContext, args ...object.Object) object.Object {
+			if len(args) != 3 {
+				return newError("wrong number of arguments. got=%d, want=3", len(args))
+			}
+
+			for _, arg := range args {
+				if arg.Type() != object.STRING {
+					return newError("argument to `replace` must be STRING, got %s", arg.Type())
+				}
+			}
+
+			str := args[0].(*object.String).Value
+			target := args[1].(*object.String).Value
+			replacement := args[2].(*object.String).Value
+			return &object.String{Value: strings.ReplaceAll(str, target, replacement)}
+		},
+	},
+
+	"index_of": {
+		Fn: func(ctx *object.CallContext, args ...object.Object) object.Object {
+			if len(args) != 2 {
+				return newError("wrong number of arguments. got=%d, want=2", len(args))
+			}
+
+			for _, arg := range args {
+				if arg.Type() != object.STRING {
+					return newError("argument to `index_of` must be STRING, got %s", arg.Type())
+				}
+			}
+
+			str := args[0].(*object.String).Value
+			idx := strings.Index(str, args[1].(*object.String).Value)
+			if idx < 0 {
+				return &object.Integer{Value: -1}
+			}
+			// report the position in runes to match indexing and len
+			return &object.Integer{Value: int64(utf8.RuneCountInString(str[:idx]))}
+		},
+	},
+
+	"repeat": {
+		Fn: func(ctx *object.CallContext, args ...object.Object) object.Object {
+			if len(args) != 2 {
+				return newError("wrong number of arguments. got=%d, want=2", len(args))
+			}
+
+			if args[0].Type() != object.STRING {
+				return newError("argument to `repeat` must be STRING, got %s", args[0].Type())
+			}
+
+			if args[1].Type() != object.INTEGER {
+				return newError("argument to `repeat` must be INTEGER, got %s", args[1].Type())
+			}
+
+			count := args[1].(*object.Integer).Value
+			if count < 0 {
+				return newError("argument to `repeat` must not be negative, got %d", count)
+			}
+
+			// Refuse before building a result that would blow the budget, or
+			// that could not be built at all
+			value := args[0].(*object.String).Value
+			if count > math.MaxInt || (len(value) > 0 && count > int64(math.MaxInt/len(value))) {
+				return newError("result of `repeat` is too large: %d times %d bytes", count, len(value))
+			}
+			if err := checkAllocation(ctx.Runtime, int64(len(value)), count); err != nil {
+				return err
+			}
+			if err := checkGas(ctx.Runtime, "repeat", int64(len(value))*count); err != nil {
+				return err
+			}
+			return &object.String{Value: strings.Repeat(value, int(count))}
+		},
+	},
+
+	"format": {
+		Fn: func(ctx *object.CallContext, args ...object.Object) object.Object {
+			if len(args) < 1 {
+				return newError("wrong number of arguments. got=%d, want at least 1", len(args))
+			}
+
+			if args[0].Type() != object.STRING {
+				return newError("argument to `format` must be STRING, got %s", args[0].Type())
+			}
+
+			return formatString(args[0].(*object.String).Value, args[1:])
+		},
+	},
+
+	"type": {
+		Fn: func(ctx *object.CallContext, args ...object.Object) object.Object {
+			if len(args) != 1 {
+				return newError("wrong number of arguments. got=%d, want=1", len(args))
+			}
+
+			return &object.String{Value: string(args[0].Type())}
+		},
+	},
+
+	"str": {
+		Fn: func(ctx *object.CallContext, args ...object.Object) object.Object {
+			if len(args) != 1 {
+				return newError("wrong number of arguments. got=%d, want=1", len(args))
+			}
+
+			if str, ok := args[0].(*object.String); ok {
+				return str
+			}
+			return &object.String{Value: args[0].Inspect()}
+		},
+	},
+
+	"int": {
+		Fn: func(ctx *object.CallContext, args ...object.Object) object.Object {
+			if len(args) != 1 {
+				return newError("wrong number of arguments. got=%d, want=1", len(args))
+			}
+
+			switch arg := args[0].(type) {
+			case *object.Integer:
+				return arg
+			case *object.Boolean:
+				if arg.Value {
+					return &object.Integer{Value: 1}
+				}
+				return &object.Integer{Value: 0}
+			case *object.String:
+				val, err := strconv.ParseInt(strings.TrimSpace(arg.Value), 10, 64)
+				if err != nil {
+					return newError("cannot convert %q to INTEGER", arg.Value)
+				}
+				return &object.Integer{Value: val}
+			default:
+				return newError("argument to `int` not supported, got %s", args[0].Type())
+			}
+		},
+	},
+
+	"bool": {
+		Fn: func(ctx *object.CallContext, args ...object.Object) object.Object {
+			if len(args) != 1 {
+				return newError("wrong number of arguments. got=%d, want=1", len(args))
+			}
+
+			return nativeBoolToBooleanObject(isTruthy(args[0]))
+		},
+	},
+
+	"inspect": {
+		Fn: func(ctx *object.CallContext, args ...object.Object) object.Object {
+			if len(args) != 1 {
+				return newError("wrong number of arguments. got=%d, want=1", len(args))
+			}
+
+			return &object.String{Value: inspectQuoted(args[0])}
+		},
+	},
+
+	"json_parse": {
+		Fn: func(ctx *object.CallContext, args ...object.Object) object.Object {
+			if len(args) != 1 {
+				return newError("wrong number of arguments. got=%d, want=1", len(args))
+			}
+
+			if args[0].Type() != object.STRING {
+				return newError("argument to `json_parse` must be STRING, got %s", args[0].Type())
+			}
+
+			res := parseJSON(args[0].(*object.String).Value)
+			if isError(res) {
+				return res
+			}
+			if err := allocateContents(ctx.Runtime, res); err != nil {
+				return err
+			}
+			return res
+		},
+	},
+
+	"json_stringify": {
+		Fn: func(ctx *object.CallContext, args ...object.Object) object.Object {
+			if len(args) != 1 && len(args) != 2 {
+				return newError("wrong number of arguments. got=%d, want=1 or 2", len(args))
+			}
+
+			options := jsonOptions{}
+			if len(args) == 2 {
+				hash, ok := args[1].(*object.Hash)
+				if !ok {
+					return newError("argument to `json_stringify` must be HASH, got %s", args[1].Type())
+				}
+
+				var err *object.Error
+				if options, err = jsonOptionsFromHash(hash); err != nil {
+					return err
+				}
+			}
+
+			return stringifyJSON(args[0], options)
+		},
+	},
+
+	"read_file": {
+		Nondeterministic: true,
+		Fn: func(ctx *object.CallContext, args ...object.Object) object.Object {
+			if len(args) != 1 {
+				return newError("wrong number of arguments. got=%d, want=1", len(args))
+			}
+
+			if args[0].Type() != object.STRING {
+				return newError("argument to `read_file` must be STRING, got %s", args[0].Type())
+			}
+
+			return readFile(ctx.Runtime, args[0].(*object.String).Value)
+		},
+	},
+
+	"write_file": {
+		Nondeterministic: true,
+		Fn: func(ctx *object.CallContext, args ...object.Object) object.Object {
+			if len(args) != 2 {
+				return newError("wrong number of arguments. got=%d, want=2", len(args))
+			}
+
+			for _, arg := range args {
+				if arg.Type() != object.STRING {
+					return newError("argument to `write_file` must be STRING, got %s", arg.Type())
+				}
+			}
+
+			return writeFile(ctx.Runtime, args[0].(*object.String).Value, args[1].(*object.String).Value)
+		},
+	},
+
+	"list_dir": {
+		Nondeterministic: true,
+		Fn: func(ctx *object.CallContext, args ...object.Object) object.Object {
+			if len(args) != 1 {
+				return newError("wrong number of arguments. got=%d, want=1", len(args))
+			}
+
+			if args[0].Type() != object.STRING {
+				return newError("argument to `list_dir` must be STRING, got %s", args[0].Type())
+			}
+
+			return listDir(ctx.Runtime, args[0].(*object.String).Value)
+		},
+	},
+
+	"exists": {
+		Nondeterministic: true,
+		Fn: func(ctx *object.CallContext, args ...object.Object) object.Object {
+			if len(args) != 1 {
+				return newError("wrong number of arguments. got=%d, want=1", len(args))
+			}
+
+			if args[0].Type() != object.STRING {
+				return newError("argument to `exists` must be STRING, got %s", args[0].Type())
+			}
+
+			return pathExists(ctx.Runtime, args[0].(*object.String).Value)
+		},
+	},
+
+	"remove": {
+		Nondeterministic: true,
+		Fn: func(ctx *object.CallContext, args ...object.Object) object.Object {
+			if len(args) != 1 {
+				return newError("wrong number of arguments. got=%d, want=1", len(args))
+			}
+
+			if args[0].Type() != object.STRING {
+				return newError("argument to `remove` must be STRING, got %s", args[0].Type())
+			}
+
+			return removePath(ctx.Runtime, args[0].(*object.String).Value)
+		},
+	},
+
+	"read_line": {
+		Nondeterministic: true,
+		Fn: func(ctx *object.CallContext, args ...object.Object) object.Object {
+			if len(args) != 0 {
+				return newError("wrong number of arguments. got=%d, want=0", len(args))
+			}
+
+			if ctx.Runtime.Stdin == nil {
+				return NULL
+			}
+
+			// read one byte at a time so that nothing past the line is
+			// consumed from the host's reader
+			var line []byte
+			buf := make([]byte, 1)
+			for {
+				n, err := ctx.Runtime.Stdin.Read(buf)
+				if n == 1 {
+					if buf[0] == '\n' {
+						break
+					}
+					line = append(line, buf[0])
+				}
+
+				if err == io.EOF {
+					if len(line) == 0 {
+						return NULL
+					}
+					break
+				}
+
+				if err != nil {
+					return newError("cannot read from stdin: %s", err)
+				}
+			}
+
+			return &object.String{Value: strings.TrimSuffix(string(line), "\r")}
+		},
+	},
+
 	"puts": {
-		Fn: func(args ...object.Object) object.Object {
+		Nondeterministic: true,
+		Fn: func(ctx *object.CallContext, args ...object.Object) object.Object {
 			for _, arg := range args {
-				fmt.Println(arg.Inspect())
+				fmt.Fprintln(ctx.Runtime.Stdout, arg.Inspect())
 			}
 			return NULL
 		},
 	},
+
+	"print": {
+		Nondeterministic: true,
+		Fn: func(ctx *object.CallContext, args ...object.Object) object.Object {
+			for _, arg := range args {
+				fmt.Fprint(ctx.Runtime.Stdout, arg.Inspect())
+			}
+			return NULL
+		},
+	},
+
+	"eprint": {
+		Nondeterministic: true,
+		Fn: func(ctx *object.CallContext, args ...object.Object) object.Object {
+			for _, arg := range args {
+				fmt.Fprint(ctx.Runtime.Stderr, arg.Inspect())
+			}
+			return NULL
+		},
+	},
+}
+
+// lessThan orders integers and strings, the types `sort` can handle without
+// a comparator
+func lessThan(left object.Object, right object.Object) (bool, *object.Error) {
+	switch left := left.(type) {
+	case *object.Integer:
+		if right, ok := right.(*object.Integer); ok {
+			return left.Value < right.Value, nil
+		}
+	case *object.String:
+		if right, ok := right.(*object.String); ok {
+			return left.Value < right.Value, nil
+		}
+	}
+
+	return false, newError("cannot compare %s and %s", left.Type(), right.Type())
+}
+
+// formatString implements the printf style verbs supported by `format`:
+// %d for integers, %s for strings, %v for any value and %% for a percent sign
+func formatString(format string, args []object.Object) object.Object {
+	var out strings.Builder
+	next := 0
+
+	for i := 0; i < len(format); i++ {
+		if format[i] != '%' {
+			out.WriteByte(format[i])
+			continue
+		}
+
+		i++
+		if i >= len(format) {
+			return newError("format string ends with a lone %%")
+		}
+
+		verb := format[i]
+		if verb == '%' {
+			out.WriteByte('%')
+			continue
+		}
+
+		if next >= len(args) {
+			return newError("missing argument for %%%c in format string", verb)
+		}
+		arg := args[next]
+		next++
+
+		switch verb {
+		case 'd':
+			if arg.Type() != object.INTEGER {
+				return newError("%%d expects INTEGER, got %s", arg.Type())
+			}
+			out.WriteString(arg.Inspect())
+		case 's':
+			if arg.Type() != object.STRING {
+				return newError("%%s expects STRING, got %s", arg.Type())
+			}
+			out.WriteString(arg.Inspect())
+		case 'v':
+			out.WriteString(arg.Inspect())
+		default:
+			return newError("unknown verb %%%c in format string", verb)
+		}
+	}
+
+	if next < len(args) {
+		return newError("too many arguments for format string. got=%d, want=%d", len(args), next)
+	}
+
+	return &object.String{Value: out.String()}
+}
+
+// inspectQuoted works like Inspect but quotes strings, including the ones
+// nested in arrays and hashes, so that "1" and 1 can be told apart. Like
+// Inspect it shows a container holding itself as [...] or {...}.
+func inspectQuoted(obj object.Object) string {
+	return inspectQuotedIn(obj, map[object.Object]bool{})
+}
+
+func inspectQuotedIn(obj object.Object, printing map[object.Object]bool) string {
+	switch obj := obj.(type) {
+	case *object.String:
+		return strconv.Quote(obj.Value)
+	case *object.Array:
+		if printing[obj] {
+			return "[...]"
+		}
+		printing[obj] = true
+		defer delete(printing, obj)
+
+		elements := make([]string, len(obj.Elements))
+		for i, el := range obj.Elements {
+			elements[i] = inspectQuotedIn(el, printing)
+		}
+		return "[" + strings.Join(elements, ", ") + "]"
+	case *object.Hash:
+		if printing[obj] {
+			return "{...}"
+		}
+		printing[obj] = true
+		defer delete(printing, obj)
+
+		pairs := []string{}
+		for _, pair := range obj.Pairs() {
+			pairs = append(pairs, inspectQuotedIn(pair.Key, printing)+": "+inspectQuotedIn(pair.Value, printing))
+		}
+		return "{" + strings.Join(pairs, ", ") + "}"
+	default:
+		return obj.Inspect()
+	}
 }
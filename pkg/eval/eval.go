@@ -5,6 +5,7 @@ import (
 
 	"github.com/AhmedThresh/not-even-a-compiler/pkg/ast"
 	"github.com/AhmedThresh/not-even-a-compiler/pkg/object"
+	"github.com/AhmedThresh/not-even-a-compiler/pkg/token"
 )
 
 var (
@@ -14,6 +15,10 @@ var (
 )
 
 func Eval(node ast.Node, env *object.Environment) object.Object {
+	if err := step(env.Runtime(), node); err != nil {
+		return err
+	}
+
 	switch node := node.(type) {
 	// Statements
 	case *ast.Program:
@@ -28,18 +33,33 @@ func Eval(node ast.Node, env *object.Environment) object.Object {
 			return val
 		}
 
+		if node.Pattern != nil {
+			if err := bindPattern(node.Pattern, val, env); err != nil {
+				return err
+			}
+			return nil
+		}
+
+		if err := allocate(env.Runtime(), bindingSize); err != nil {
+			return err
+		}
+
+		if fn, ok := val.(*object.Function); ok && fn.Name == "" {
+			fn.Name = node.Name.Value
+		}
+
 		env.Store(node.Name.Value, val)
 
 	// Expressions
 	case *ast.IntegerLiteral:
-		return &object.Integer{
+		return track(env.Runtime(), &object.Integer{
 			Value: node.Value,
-		}
+		})
 
 	case *ast.StringLiteral:
-		return &object.String{
+		return track(env.Runtime(), &object.String{
 			Value: node.Value,
-		}
+		})
 
 	case *ast.PrefixExpression:
 		right := Eval(node.Right, env)
@@ -47,7 +67,7 @@ func Eval(node ast.Node, env *object.Environment) object.Object {
 			return right
 		}
 
-		return evalPrefixExpression(right, node.Operator)
+		return track(env.Runtime(), evalPrefixExpression(right, node.Operator))
 
 	case *ast.Boolean:
 		return nativeBoolToBooleanObject(node.Value)
@@ -63,26 +83,42 @@ func Eval(node ast.Node, env *object.Environment) object.Object {
 			return left
 		}
 
-		return evalInfixExpression(right, left, node.Operator)
+		return track(env.Runtime(), evalInfixExpression(right, left, node.Operator))
 
 	case *ast.IfExpression:
 		return evalIfExpression(node.Condition, node.Consequence, node.Alternative, env)
 
+	case *ast.ConditionalExpression:
+		return evalConditionalExpression(node, env)
+
 	case *ast.BlockStatement:
 		return evalBlockStatements(node, env)
 
+	case *ast.ExportStatement:
+		return evalExportStatement(node, env)
+
+	case *ast.ImportExpression:
+		return evalImportExpression(node, env)
+
+	case *ast.ThrowStatement:
+		val := Eval(node.Value, env)
+		if isError(val) {
+			return val
+		}
+		return throwError(val)
+
 	case *ast.ReturnStatement:
 		val := Eval(node.Value, env)
 		if isError(val) {
 			return val
 		}
-		return &object.ReturnValue{Value: val}
+		return track(env.Runtime(), &object.ReturnValue{Value: val})
 
 	case *ast.Identifier:
 		return evalIdentifier(node, env)
 
 	case *ast.FunctionLiteral:
-		return &object.Function{Body: node.Body, Parameters: node.Parameters, Env: env}
+		return track(env.Runtime(), &object.Function{Body: node.Body, Parameters: node.Parameters, Env: env})
 
 	case *ast.CallExpression:
 		return evalCallExpression(node, env)
@@ -96,6 +132,15 @@ func Eval(node ast.Node, env *object.Environment) object.Object {
 	case *ast.IndexExpression:
 		return evalIndexExpression(node, env)
 
+	case *ast.SliceExpression:
+		return evalSliceExpression(node, env)
+
+	case *ast.MatchExpression:
+		return evalMatchExpression(node, env)
+
+	case *ast.TryExpression:
+		return evalTryExpression(node, env)
+
 	default:
 		fmt.Println("aaa")
 		return NULL
@@ -184,6 +229,15 @@ func evalInfixExpression(right object.Object, left object.Object, operator strin
 		return evalStringInfixOperation(right, left, operator)
 	}
 
+	if isStructural(left) || isStructural(right) {
+		switch operator {
+		case "==":
+			return nativeBoolToBooleanObject(left.Equals(right))
+		case "!=":
+			return nativeBoolToBooleanObject(!left.Equals(right))
+		}
+	}
+
 	if left.Type() != right.Type() {
 		return newError("type mismatch: %s %s %s", left.Type(), operator, right.Type())
 	}
@@ -221,6 +275,9 @@ func evalIntegerInfixOperation(right object.Object, left object.Object, operator
 	case "*":
 		return &object.Integer{Value: leftVal * rightVal}
 	case "/":
+		if rightVal == 0 {
+			return newError("division by zero: %d / 0", leftVal)
+		}
 		return &object.Integer{Value: leftVal / rightVal}
 	case "<":
 		return nativeBoolToBooleanObject(leftVal < rightVal)
@@ -236,15 +293,22 @@ func evalIntegerInfixOperation(right object.Object, left object.Object, operator
 }
 
 func evalStringInfixOperation(right object.Object, left object.Object, operator string) object.Object {
-	if operator != "+" {
-		return newError("unknown operator: %s %s %s", left.Type(), operator, right.Type())
+	rightVal := right.(*object.String).Value
+	leftVal := left.(*object.String).Value
+	switch operator {
+	case "+":
+		return &object.String{Value: leftVal + rightVal}
+	case "<":
+		return nativeBoolToBooleanObject(leftVal < rightVal)
+	case ">":
+		return nativeBoolToBooleanObject(leftVal > rightVal)
+	case "==":
+		return nativeBoolToBooleanObject(leftVal == rightVal)
+	case "!=":
+		return nativeBoolToBooleanObject(leftVal != rightVal)
 	}
-	rightVal := right.(*object.String)
-	leftVal := left.(*object.String)
 
-	return &object.String{
-		Value: leftVal.Value + rightVal.Value,
-	}
+	return newError("unknown operator: %s %s %s", left.Type(), operator, right.Type())
 }
 
 func evalIfExpression(condition ast.Expression, consequence *ast.BlockStatement, alternative *ast.BlockStatement, env *object.Environment) object.Object {
@@ -253,7 +317,7 @@ func evalIfExpression(condition ast.Expression, consequence *ast.BlockStatement,
 		return conditionValue
 	}
 
-	if conditionValue == FALSE || conditionValue == NULL {
+	if !isTruthy(conditionValue) {
 		if alternative != nil {
 			return Eval(alternative, env)
 		}
@@ -262,6 +326,18 @@ func evalIfExpression(condition ast.Expression, consequence *ast.BlockStatement,
 	return Eval(consequence, env)
 }
 
+func evalConditionalExpression(node *ast.ConditionalExpression, env *object.Environment) object.Object {
+	conditionValue := Eval(node.Condition, env)
+	if isError(conditionValue) {
+		return conditionValue
+	}
+
+	if isTruthy(conditionValue) {
+		return Eval(node.Consequence, env)
+	}
+	return Eval(node.Alternative, env)
+}
+
 func evalBlockStatements(block *ast.BlockStatement, env *object.Environment) object.Object {
 	var result object.Object
 
@@ -280,7 +356,7 @@ func evalIdentifier(node *ast.Identifier, env *object.Environment) object.Object
 		return val
 	}
 
-	if fn, ok := builtins[node.Value]; ok {
+	if fn, ok := lookupBuiltin(env, node.Value); ok {
 		return fn
 	}
 
@@ -293,27 +369,143 @@ func evalCallExpression(node *ast.CallExpression, env *object.Environment) objec
 		return fn
 	}
 
-	arguments := evalExpressions(node.Arguments, env)
-	if len(arguments) == 1 && isError(arguments[0]) {
-		return arguments[0]
+	arguments, named, err := evalArguments(node.Arguments, env)
+	if err != nil {
+		return err
 	}
 
-	return applyFunction(fn, arguments)
+	return callFunction(fn, arguments, named, node.Token, env)
 
 }
 
-func applyFunction(fn object.Object, args []object.Object) object.Object {
+// namedArgument is an argument passed by parameter name
+type namedArgument struct {
+	name  string
+	value object.Object
+}
+
+// evalArguments evaluates the arguments of a call, expanding spread arrays
+// into positional arguments and setting named arguments apart
+func evalArguments(arguments []ast.Expression, env *object.Environment) ([]object.Object, []namedArgument, object.Object) {
+	positional := []object.Object{}
+	named := []namedArgument{}
+
+	for _, arg := range arguments {
+		switch arg := arg.(type) {
+		case *ast.NamedArgument:
+			val := Eval(arg.Value, env)
+			if isError(val) {
+				return nil, nil, val
+			}
+			named = append(named, namedArgument{name: arg.Name.Value, value: val})
+		case *ast.SpreadExpression:
+			if len(named) > 0 {
+				return nil, nil, newError("positional argument follows named argument `%s`", named[len(named)-1].name)
+			}
+
+			val := Eval(arg.Value, env)
+			if isError(val) {
+				return nil, nil, val
+			}
+
+			arr, ok := val.(*object.Array)
+			if !ok {
+				return nil, nil, newError("cannot spread %s, want ARRAY", val.Type())
+			}
+			positional = append(positional, arr.Elements...)
+		default:
+			if len(named) > 0 {
+				return nil, nil, newError("positional argument follows named argument `%s`", named[len(named)-1].name)
+			}
+
+			val := Eval(arg, env)
+			if isError(val) {
+				return nil, nil, val
+			}
+			positional = append(positional, val)
+		}
+	}
+
+	return positional, named, nil
+}
+
+// Apply calls a FUNCTION or BUILTIN object with already evaluated arguments,
+// so that host applications can invoke script functions
+func Apply(fn object.Object, args []object.Object, env *object.Environment) object.Object {
+	return applyFunction(fn, args, env)
+}
+
+// applyFunction calls fn with args on behalf of code running in env
+func applyFunction(fn object.Object, args []object.Object, env *object.Environment) object.Object {
+	return callFunction(fn, args, nil, token.Token{}, env)
+}
+
+// callFunction calls fn, site is the token of the call expression and stays
+// empty for calls that have none in the source
+func callFunction(fn object.Object, args []object.Object, named []namedArgument, site token.Token, env *object.Environment) object.Object {
 	switch fn := fn.(type) {
 	case *object.Function:
-		extendedEnv := extendEnv(fn, args)
+		if err := enterCall(env.Runtime()); err != nil {
+			return err
+		}
+		defer leaveCall(env.Runtime())
+
+		if err := allocate(env.Runtime(), environmentSize+bindingSize*int64(len(fn.Parameters))); err != nil {
+			return err
+		}
+
+		frame := object.StackFrame{Function: functionName(fn), Line: site.Line, Column: site.Column}
+
+		// errors binding the arguments, such as a wrong arity, are reported
+		// from within the call like those raised by its body
+		extendedEnv, err := extendEnv(fn, args, named)
+		if err != nil {
+			if err, ok := err.(*object.Error); ok {
+				err.Stack = append(err.Stack, frame)
+			}
+			return err
+		}
 		evaluated := Eval(fn.Body, extendedEnv)
+		if err, ok := evaluated.(*object.Error); ok {
+			err.Stack = append(err.Stack, frame)
+		}
 		return unwrapRetunValue(evaluated)
 	case *object.Builtin:
-		if res := fn.Fn(args...); res != nil {
-			return res
-		} else {
+		if len(named) > 0 {
+			return newError("builtin `%s` does not take named arguments", fn.Name)
+		}
+
+		if fn.Nondeterministic && env.Runtime().Deterministic {
+			return newError("`%s` is not available in deterministic mode", fn.Name)
+		}
+
+		if err := chargeBuiltin(env.Runtime(), fn, args); err != nil {
+			return err
+		}
+
+		ctx := &object.CallContext{
+			Runtime: env.Runtime(),
+			Apply: func(fn object.Object, args ...object.Object) object.Object {
+				return applyFunction(fn, args, env)
+			},
+		}
+		res := fn.Fn(ctx, args...)
+		if res == nil {
 			return NULL
 		}
+
+		if err := chargeBuiltinResult(env.Runtime(), fn, res); err != nil {
+			return err
+		}
+
+		// Builtins such as push may return one of their arguments, which
+		// is already accounted for
+		for _, arg := range args {
+			if res == arg {
+				return res
+			}
+		}
+		return track(env.Runtime(), res)
 	default:
 		return newError("not a function: %s", fn.Type())
 	}
@@ -324,7 +516,7 @@ func evalArrayExpression(node *ast.Array, env *object.Environment) object.Object
 	if len(elements) == 1 && isError(elements[0]) {
 		return elements[0]
 	}
-	return &object.Array{Elements: elements}
+	return track(env.Runtime(), &object.Array{Elements: elements})
 }
 
 func evalIndexExpression(node *ast.IndexExpression, env *object.Environment) object.Object {
@@ -338,14 +530,24 @@ func evalIndexExpression(node *ast.IndexExpression, env *object.Environment) obj
 		return index
 	}
 
+	// Indexing a string creates a new one, arrays and hashes hand out
+	// elements that already exist
+	if left.Type() == object.STRING {
+		return track(env.Runtime(), applyIndex(left, index))
+	}
 	return applyIndex(left, index)
 }
 
 func applyIndex(left object.Object, index object.Object) object.Object {
+	left = exportsOf(left)
 	if left.Type() == object.ARRAY && index.Type() == object.INTEGER {
 		return applyIndexOnArray(left, index)
 	}
 
+	if left.Type() == object.STRING && index.Type() == object.INTEGER {
+		return applyIndexOnString(left, index)
+	}
+
 	if left.Type() == object.HASH {
 		return applyIndexOnHash(left, index)
 	}
@@ -355,67 +557,263 @@ func applyIndex(left object.Object, index object.Object) object.Object {
 
 func applyIndexOnArray(left object.Object, index object.Object) object.Object {
 	arr := left.(*object.Array)
-	idx := index.(*object.Integer).Value
-	max := len(arr.Elements) - 1
-
-	if idx < 0 || idx > int64(max) {
+	idx, ok := normalizeIndex(index.(*object.Integer).Value, len(arr.Elements))
+	if !ok {
 		return NULL
 	}
 
 	return arr.Elements[idx]
 }
 
+func applyIndexOnString(left object.Object, index object.Object) object.Object {
+	runes := []rune(left.(*object.String).Value)
+	idx, ok := normalizeIndex(index.(*object.Integer).Value, len(runes))
+	if !ok {
+		return NULL
+	}
+
+	return &object.String{Value: string(runes[idx])}
+}
+
+// normalizeIndex resolves negative indices relative to the end of a sequence
+// of the given length and reports whether the result is within bounds
+func normalizeIndex(idx int64, length int) (int64, bool) {
+	if idx < 0 {
+		idx += int64(length)
+	}
+
+	if idx < 0 || idx >= int64(length) {
+		return 0, false
+	}
+
+	return idx, true
+}
+
+func evalSliceExpression(node *ast.SliceExpression, env *object.Environment) object.Object {
+	left := Eval(node.Left, env)
+	if isError(left) {
+		return left
+	}
+
+	var start, end object.Object = NULL, NULL
+	if node.Start != nil {
+		start = Eval(node.Start, env)
+		if isError(start) {
+			return start
+		}
+	}
+
+	if node.End != nil {
+		end = Eval(node.End, env)
+		if isError(end) {
+			return end
+		}
+	}
+
+	return track(env.Runtime(), applySlice(left, start, end))
+}
+
+// applySlice slices arrays and strings between start and end, where NULL
+// bounds stand for the beginning and the end of the sequence
+func applySlice(left object.Object, start object.Object, end object.Object) object.Object {
+	for _, bound := range []object.Object{start, end} {
+		if bound != NULL && bound.Type() != object.INTEGER {
+			return newError("slice index must be INTEGER, got %s", bound.Type())
+		}
+	}
+
+	switch left := left.(type) {
+	case *object.Array:
+		from, to := sliceBounds(start, end, len(left.Elements))
+		return sliceArray(left, from, to)
+	case *object.String:
+		runes := []rune(left.Value)
+		from, to := sliceBounds(start, end, len(runes))
+		return &object.String{Value: string(runes[from:to])}
+	default:
+		return newError("slice operator not supported: %s", left.Type())
+	}
+}
+
+// sliceBounds turns optional, possibly negative, slice bounds into valid
+// indices for a sequence of the given length, clamping them when needed
+func sliceBounds(start object.Object, end object.Object, length int) (int, int) {
+	clamp := func(bound object.Object, fallback int) int {
+		if bound == NULL {
+			return fallback
+		}
+
+		idx := bound.(*object.Integer).Value
+		if idx < 0 {
+			idx += int64(length)
+		}
+
+		if idx < 0 {
+			return 0
+		}
+		if idx > int64(length) {
+			return length
+		}
+		return int(idx)
+	}
+
+	from, to := clamp(start, 0), clamp(end, length)
+	if from > to {
+		from = to
+	}
+
+	return from, to
+}
+
+// sliceArray copies the elements so that pushing onto the result does not
+// overwrite elements of the original array
+func sliceArray(arr *object.Array, from int, to int) *object.Array {
+	elements := make([]object.Object, to-from)
+	copy(elements, arr.Elements[from:to])
+	return &object.Array{Elements: elements}
+}
+
 func applyIndexOnHash(left object.Object, index object.Object) object.Object {
 	hash := left.(*object.Hash)
-	hashKey, ok := index.(object.Hashable)
-	if !ok {
+	if !object.IsHashable(index) {
 		return newError("unusable as hash key: %s", index.Type())
 	}
 
-	if pair, ok := hash.Pairs[hashKey.HashKey()]; !ok {
+	if value, ok := hash.Get(index); !ok {
 		return NULL
 	} else {
-		return pair.Value
+		return value
 	}
 }
 
 func evalHashLiteralExpression(hash *ast.HashLiteral, env *object.Environment) object.Object {
-	hashValue := object.Hash{
-		Pairs: make(map[object.HashKey]object.HashPair),
-	}
+	hashValue := object.NewHash()
 
-	for key, val := range hash.Pairs {
-		k := Eval(key, env)
+	for _, pair := range hash.Pairs {
+		k := Eval(pair.Key, env)
 		if isError(k) {
 			return k
 		}
 
-		h, ok := k.(object.Hashable)
-		if !ok {
+		if !object.IsHashable(k) {
 			return newError("unusable as hash key: %s", k.Type())
 		}
 
-		v := Eval(val, env)
+		v := Eval(pair.Value, env)
 		if isError(v) {
 			return v
 		}
 
-		hashValue.Pairs[h.HashKey()] = object.HashPair{
-			Key:   k,
-			Value: v,
-		}
+		hashValue.Set(k, v)
 	}
 
-	return &hashValue
+	return track(env.Runtime(), hashValue)
 }
 
-func extendEnv(function *object.Function, args []object.Object) *object.Environment {
+// extendEnv binds the arguments of a call to the parameters of function.
+// Parameters left without an argument get their default value, evaluated in
+// the new environment so that it can refer to the parameters before it.
+func extendEnv(function *object.Function, args []object.Object, named []namedArgument) (*object.Environment, object.Object) {
+	params := function.Parameters
+	hasRest := len(params) > 0 && params[len(params)-1].Rest
+	if !hasRest && len(args) > len(params) {
+		return nil, arityError(function, len(args)+len(named))
+	}
+
+	byName := make(map[string]object.Object, len(named))
+	for _, arg := range named {
+		idx := -1
+		for i, param := range params {
+			if param.Name != nil && param.Name.Value == arg.name && !param.Rest {
+				idx = i
+			}
+		}
+
+		if idx == -1 {
+			return nil, newError("unknown argument `%s` to %s", arg.name, describeFunction(function))
+		}
+
+		if _, ok := byName[arg.name]; ok || idx < len(args) {
+			return nil, newError("argument `%s` to %s given twice", arg.name, describeFunction(function))
+		}
+		byName[arg.name] = arg.value
+	}
+
 	env := object.NewEnclosedEnvironment(function.Env)
-	for i, identifier := range function.Parameters {
-		env.Store(identifier.Value, args[i])
+	for i, param := range params {
+		if param.Rest {
+			rest := []object.Object{}
+			if i < len(args) {
+				rest = append(rest, args[i:]...)
+			}
+
+			arr := track(env.Runtime(), &object.Array{Elements: rest})
+			if isError(arr) {
+				return nil, arr
+			}
+			env.Store(param.Name.Value, arr)
+			continue
+		}
+
+		var val object.Object
+		if i < len(args) {
+			val = args[i]
+		} else if param.Name != nil && byName[param.Name.Value] != nil {
+			val = byName[param.Name.Value]
+		} else if param.Default != nil {
+			val = Eval(param.Default, env)
+			if isError(val) {
+				return nil, val
+			}
+		} else {
+			return nil, arityError(function, len(args)+len(named))
+		}
+
+		if param.Pattern != nil {
+			if err := bindPattern(param.Pattern, val, env); err != nil {
+				return nil, err
+			}
+			continue
+		}
+		env.Store(param.Name.Value, val)
 	}
 
-	return env
+	return env, nil
+}
+
+// arityError reports a call to function with the wrong number of arguments
+func arityError(function *object.Function, got int) *object.Error {
+	required, max := 0, 0
+	for _, param := range function.Parameters {
+		switch {
+		case param.Rest:
+			return newError("wrong number of arguments to %s. got=%d, want at least %d", describeFunction(function), got, required)
+		case param.Default == nil:
+			required++
+		}
+		max++
+	}
+
+	if required == max {
+		return newError("wrong number of arguments to %s. got=%d, want=%d", describeFunction(function), got, required)
+	}
+	return newError("wrong number of arguments to %s. got=%d, want %d to %d", describeFunction(function), got, required, max)
+}
+
+// functionName names fn in stack traces
+func functionName(fn *object.Function) string {
+	if fn.Name == "" {
+		return "<anonymous>"
+	}
+	return fn.Name
+}
+
+// describeFunction names fn for error messages
+func describeFunction(fn *object.Function) string {
+	if fn.Name == "" {
+		return "anonymous function"
+	}
+	return "`" + fn.Name + "`"
 }
 
 func unwrapRetunValue(val object.Object) object.Object {
@@ -426,6 +824,25 @@ func unwrapRetunValue(val object.Object) object.Object {
 	return val
 }
 
+// isStructural reports whether the object is compared with == and != by
+// structure rather than through a type specific infix operation
+func isStructural(obj object.Object) bool {
+	switch obj.Type() {
+	case object.ARRAY, object.HASH, object.NULL:
+		return true
+	default:
+		return false
+	}
+}
+
+func isCallable(obj object.Object) bool {
+	return obj.Type() == object.FUNCTION || obj.Type() == object.BUILTIN
+}
+
+func isTruthy(obj object.Object) bool {
+	return obj != FALSE && obj != NULL
+}
+
 func isError(obj object.Object) bool {
 	if obj != nil {
 		return obj.Type() == object.ERROR_OBJ
@@ -1,6 +1,12 @@
 package eval
 
 import (
+	"bytes"
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
 	"testing"
 
 	"github.com/AhmedThresh/not-even-a-compiler/pkg/lexer"
@@ -60,6 +66,32 @@ func TestEvalBooleanExpression(t *testing.T) {
 		{"(1 < 2) == false", false},
 		{"(1 > 2) == true", false},
 		{"(1 > 2) == false", true},
+		{`"a" == "a"`, true},
+		{`"a" != "a"`, false},
+		{`"a" == "b"`, false},
+		{`"a" < "b"`, true},
+		{`"b" < "a"`, false},
+		{`"abc" > "abd"`, false},
+		{`"héllo" == "héllo"`, true},
+		{"[1, 2] == [1, 2]", true},
+		{"[1, 2] != [1, 2]", false},
+		{"[1, 2] == [2, 1]", false},
+		{"[1, 2] == [1, 2, 3]", false},
+		{`[1, "a", [true]] == [1, "a", [true]]`, true},
+		{`[1, "a", [true]] == [1, "a", [false]]`, false},
+		{"[] == []", true},
+		{"[1] == 1", false},
+		{"1 != [1]", true},
+		{`{"a": 1, "b": [2]} == {"b": [2], "a": 1}`, true},
+		{`{"a": 1} == {"a": 2}`, false},
+		{`{"a": 1} == {"a": 1, "b": 2}`, false},
+		{`{} == []`, false},
+		{"if (false) { 1 } == if (false) { 2 }", true},
+		{"if (false) { 1 } == []", false},
+		{"let f = fn() { 1 }; [f] == [f]", true},
+		{"[fn() { 1 }] == [fn() { 1 }]", false},
+		{"let a = []; push(a, a); a == [a]", true},
+		{"let a = []; push(a, a); let b = []; push(b, b); a == b", false},
 	}
 
 	for _, tt := range tests {
@@ -98,6 +130,13 @@ func TestIfElseExpressions(t *testing.T) {
 		{"if (1 > 2) { 10 }", nil},
 		{"if (1 > 2) { 10 } else { 20 }", 20},
 		{"if (1 < 2) { 10 } else { 20 }", 10},
+		{"if (1 > 2) { 10 } else if (2 > 1) { 20 } else { 30 }", 20},
+		{"if (1 > 2) { 10 } else if (2 > 3) { 20 } else { 30 }", 30},
+		{"if (1 > 2) { 10 } else if (2 > 3) { 20 }", nil},
+		{"true ? 10 : 20", 10},
+		{"1 > 2 ? 10 : 20", 20},
+		{"false ? 10 : true ? 20 : 30", 20},
+		{"let x = 5; x > 3 ? x * 2 : x", 10},
 	}
 
 	for _, tt := range tests {
@@ -189,6 +228,26 @@ if (10 > 1) {
 			`{"name": "Monkey"}[fn(x) { x }];`,
 			"unusable as hash key: FUNCTION",
 		},
+		{
+			`{[1, fn(x) { x }]: 1}`,
+			"unusable as hash key: ARRAY",
+		},
+		{
+			"let a = []; push(a, a); {a: 1}",
+			"unusable as hash key: ARRAY",
+		},
+		{
+			"let a = [1]; push(a, a); {}[a]",
+			"unusable as hash key: ARRAY",
+		},
+		{
+			"10 / 0",
+			"division by zero: 10 / 0",
+		},
+		{
+			"let f = fn(x) { 1 / x }; f(1); f(0)",
+			"division by zero: 1 / 0",
+		},
 	}
 
 	for _, tt := range tests {
@@ -261,6 +320,427 @@ func TestFunctionApplication(t *testing.T) {
 	}
 }
 
+func TestFunctionArity(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"let add = fn(a, b) { a + b }; add(1)", "wrong number of arguments to `add`. got=1, want=2"},
+		{"let add = fn(a, b) { a + b }; add(1, 2, 3)", "wrong number of arguments to `add`. got=3, want=2"},
+		{"fn(a) { a }()", "wrong number of arguments to anonymous function. got=0, want=1"},
+		{"let add = fn(a, b) { a + b }; let plus = add; plus(1)", "wrong number of arguments to `add`. got=1, want=2"},
+		{"let adder = fn(x) { fn(y) { x + y } }; let addTwo = adder(2); addTwo()", "wrong number of arguments to `addTwo`. got=0, want=1"},
+		{"map([1, 2], fn(a, b) { a })", "wrong number of arguments to anonymous function. got=1, want=2"},
+	}
+
+	for _, tt := range tests {
+		testErrorObject(t, testEval(tt.input), tt.expected)
+	}
+}
+
+func TestDefaultRestAndNamedArguments(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected interface{}
+	}{
+		{"let f = fn(a, b = 2) { a + b }; f(1)", 3},
+		{"let f = fn(a, b = 2) { a + b }; f(1, 5)", 6},
+		{"let f = fn(a, b = a * 10) { a + b }; f(3)", 33},
+		{"let base = 100; let f = fn(a, b = base) { a + b }; let base = 1; f(1)", 2},
+		{"let f = fn(a, b = 2, c = 3) { [a, b, c] }; f(1, c = 30)", "[1, 2, 30]"},
+		{"let f = fn(a, b) { a - b }; f(b = 1, a = 5)", 4},
+		{"let f = fn(a, ...rest) { rest }; f(1, 2, 3)", "[2, 3]"},
+		{"let f = fn(a, ...rest) { rest }; f(1)", "[]"},
+		{"let f = fn(...xs) { len(xs) }; f(...[1, 2], 3, ...[])", 3},
+		{"let add = fn(a, b, c) { a + b + c }; let xs = [1, 2, 3]; add(...xs)", 6},
+		{"push(...[[1], 2])", "[1, 2]"},
+		{"let x = 1; let outer = fn() { fn() { fn() { x } } }; outer()()()", 1},
+		{"let f = fn(a, b = 2) { a + b }; f(1, 2, 3)", errorMessage("wrong number of arguments to `f`. got=3, want 1 to 2")},
+		{"let f = fn(a, b = 2) { a + b }; f()", errorMessage("wrong number of arguments to `f`. got=0, want 1 to 2")},
+		{"let f = fn(a, ...rest) { a }; f()", errorMessage("wrong number of arguments to `f`. got=0, want at least 1")},
+		{"let f = fn(a) { a }; f(b = 1)", errorMessage("unknown argument `b` to `f`")},
+		{"let f = fn(a) { a }; f(1, a = 1)", errorMessage("argument `a` to `f` given twice")},
+		{"let f = fn(a, ...rest) { a }; f(rest = [1])", errorMessage("unknown argument `rest` to `f`")},
+		{"let f = fn(a, b) { a }; f(a = 1, 2)", errorMessage("positional argument follows named argument `a`")},
+		{"let f = fn(a, b = c) { a }; f(1)", errorMessage("identifier not found: c")},
+		{"len(...1)", errorMessage("cannot spread INTEGER, want ARRAY")},
+		{"len(a = 1)", errorMessage("builtin `len` does not take named arguments")},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+		switch expected := tt.expected.(type) {
+		case int:
+			testIntegerObject(t, evaluated, int64(expected))
+		case string:
+			if evaluated.Inspect() != expected {
+				t.Errorf("%s: wrong result. want=%s, got=%s", tt.input, expected, evaluated.Inspect())
+			}
+		case errorMessage:
+			testErrorObject(t, evaluated, string(expected))
+		}
+	}
+}
+
+func TestDestructuring(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected interface{}
+	}{
+		{"let [a, b] = [1, 2]; a + b", 3},
+		{"let [a, ...rest] = [1, 2, 3]; rest", "[2, 3]"},
+		{"let [a, ...rest] = [1]; rest", "[]"},
+		{"let [[a, b], c] = [[1, 2], 3]; a + b + c", 6},
+		{`let {name, age} = {"name": "Ann", "age": 30, "x": 1}; [name, age]`, "[Ann, 30]"},
+		{`let {name: n} = {"name": "Ann"}; n`, "Ann"},
+		{`let {name, ...others} = {"name": "Ann", "age": 30, 1: 2}; others`, "{age: 30, 1: 2}"},
+		{`let {tags: [first, ...more]} = {"tags": ["a", "b", "c"]}; [first, more]`, "[a, [b, c]]"},
+		{"let xs = [1, 2, 3]; let [_, ...tail] = xs; xs", "[1, 2, 3]"},
+		{"let sum = fn([a, b]) { a + b }; sum([3, 4])", 7},
+		{`let greet = fn({name}, greeting = "hi") { greeting + " " + name }; greet({"name": "Ann"})`, "hi Ann"},
+		{"let f = fn([a, b] = [1, 2]) { a * b }; f()", 2},
+		{"map([[1, 2], [3, 4]], fn([a, b]) { a * b })", "[2, 12]"},
+		{"let [a, b] = [1]; a", errorMessage("array pattern [a, b] expects 2 elements, got 1")},
+		{"let [a, b] = [1, 2, 3]; a", errorMessage("array pattern [a, b] expects 2 elements, got 3")},
+		{"let [a, b, ...c] = [1]; a", errorMessage("array pattern [a, b, ...c] expects at least 2 elements, got 1")},
+		{"let [a] = 1; a", errorMessage("cannot destructure INTEGER with array pattern [a]")},
+		{`let {name} = ["Ann"]; name`, errorMessage("cannot destructure ARRAY with hash pattern {name}")},
+		{`let {name, age} = {"name": "Ann"}; name`, errorMessage("hash pattern {name, age}: key not found: age")},
+		{"let f = fn([a, b]) { a }; f([1])", errorMessage("array pattern [a, b] expects 2 elements, got 1")},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+		switch expected := tt.expected.(type) {
+		case int:
+			testIntegerObject(t, evaluated, int64(expected))
+		case string:
+			if evaluated.Inspect() != expected {
+				t.Errorf("%s: wrong result. want=%s, got=%s", tt.input, expected, evaluated.Inspect())
+			}
+		case errorMessage:
+			testErrorObject(t, evaluated, string(expected))
+		}
+	}
+}
+
+func TestMatchExpressions(t *testing.T) {
+	describe := `let describe = fn(value) {
+  match (value) {
+    0 => "zero",
+    -1 => "minus one",
+    "hi" => "greeting",
+    true => "yes",
+    [] => "empty",
+    [x] => "one " + str(x),
+    [x, y] if x > y => "descending",
+    [x, y] => "pair",
+    [first, ...rest] => "first " + str(first) + " of " + str(len(rest) + 1),
+    {"type": "circle", "r": r} => "circle " + str(r),
+    {"type": "point", ...} => "some point",
+    {"name": name, ...others} => name + " and " + str(len(others)),
+    n if type(n) == "INTEGER" => "number " + str(n),
+    _ => "other",
+  }
+};
+`
+
+	tests := []struct {
+		input    string
+		expected interface{}
+	}{
+		{"describe(0)", "zero"},
+		{"describe(-1)", "minus one"},
+		{`describe("hi")`, "greeting"},
+		{"describe(true)", "yes"},
+		{"describe(false)", "other"},
+		{"describe([])", "empty"},
+		{"describe([7])", "one 7"},
+		{"describe([2, 1])", "descending"},
+		{"describe([1, 2])", "pair"},
+		{"describe([1, 2, 3])", "first 1 of 3"},
+		{`describe({"type": "circle", "r": 2})`, "circle 2"},
+		{`describe({"type": "circle", "r": 2, "color": "red"})`, "other"},
+		{`describe({"type": "point", "x": 1})`, "some point"},
+		{`describe({"name": "Ann", "age": 30, "x": 1})`, "Ann and 2"},
+		{"describe(42)", "number 42"},
+		{`describe("bye")`, "other"},
+		{"let x = 10; match ([1, 2]) { [x, 3] => x, [a, b] => x }", 10},
+		{"match (5) { 1 => 1 }", errorMessage("no match arm matches 5")},
+		{"match ([1, 2]) { [a] => a }", errorMessage("no match arm matches [1, 2]")},
+		{"match (5) { n if n + true => 1 }", errorMessage("type mismatch: INTEGER + BOOLEAN")},
+		{"match (missing) { _ => 1 }", errorMessage("identifier not found: missing")},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(describe + tt.input)
+		switch expected := tt.expected.(type) {
+		case int:
+			testIntegerObject(t, evaluated, int64(expected))
+		case string:
+			testStringObject(t, evaluated, expected)
+		case errorMessage:
+			testErrorObject(t, evaluated, string(expected))
+		}
+	}
+}
+
+func TestTryCatch(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected interface{}
+	}{
+		{`try { 1 } catch (e) { 2 }`, 1},
+		{`try { throw "boom"; 1 } catch (e) { e["message"] }`, "boom"},
+		{`try { throw "boom" } catch (e) { e["kind"] }`, "THROWN"},
+		{`try { 1 + true } catch (e) { e["message"] }`, "type mismatch: INTEGER + BOOLEAN"},
+		{`try { 1 + true } catch (e) { e["kind"] }`, "RUNTIME"},
+		{`try { throw {"code": 42} } catch (e) { e["value"]["code"] }`, 42},
+		{`try { throw {"message": "custom"} } catch (e) { e["message"] }`, "custom"},
+		{`try { throw 42 } catch (e) { e["message"] }`, "42"},
+		{`try { throw "x" } catch { "handled" }`, "handled"},
+		{`let inner = fn() { throw "deep" }; let outer = fn() { inner() }; try { outer() } catch (e) { e["stack"] }`, "[inner (line 1, column 60), outer (line 1, column 77)]"},
+		{`try { map([1], fn(x) { throw "in callback" }) } catch (e) { e["stack"] }`, "[<anonymous>]"},
+		{`try { try { throw "a" } catch (e) { throw e } } catch (e) { e["message"] }`, "a"},
+		{`try { try { 1 + true } catch (e) { throw e } } catch (e) { e["kind"] }`, "RUNTIME"},
+		{`try { try { throw {"code": 1} } catch (e) { throw e } } catch (e) { [e["kind"], e["value"]["code"]] }`, "[THROWN, 1]"},
+		{`let f = fn() { 1 + true }; let g = fn() { try { f() } catch (e) { throw e } }; try { g() } catch (e) { e["stack"] }`, "[f (line 1, column 50), g (line 1, column 87)]"},
+		{`try { throw {"message": "x", "kind": "STEP_LIMIT", "stack": []} } catch (e) { e["kind"] }`, "THROWN"},
+		{`try { throw {"message": "x", "kind": "RUNTIME", "stack": ["forged"], "value": 1} } catch (e) { [e["kind"], e["stack"]] }`, "[THROWN, []]"},
+		{`try { try { 1 + true } catch (e) { throw merge(e, {}) } } catch (e) { e["kind"] }`, "THROWN"},
+		{`let f = fn() { throw "a" }; try { try { f() } catch (e) { throw e } } catch (e) { e["value"] }`, "a"},
+		{`try { try { throw "a" } finally { 0 } } catch (e) { e["message"] }`, "a"},
+		{`try { 1 } finally { 2 }`, 1},
+		{`try { throw "a" } catch (e) { 1 } finally { 2 }`, 1},
+		{`let f = fn() { try { return 1 } finally { 2 } }; f()`, 1},
+		{`let f = fn() { try { return 1 } finally { return 2 } }; f()`, 2},
+		{`try { throw "a" } catch (e) { 1 } finally { throw "from finally" }`, errorMessage("from finally")},
+		{`try { throw "a" } catch (e) { throw "b" }`, errorMessage("b")},
+		{`throw "uncaught"; 1`, errorMessage("uncaught")},
+		{`let f = fn() { throw "in f" }; f(); 2`, errorMessage("in f")},
+		{`try { throw "x" } catch (e) { 1 }; e`, errorMessage("identifier not found: e")},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+		switch expected := tt.expected.(type) {
+		case int:
+			testIntegerObject(t, evaluated, int64(expected))
+		case string:
+			if evaluated.Inspect() != expected {
+				t.Errorf("%s: wrong result. want=%s, got=%s", tt.input, expected, evaluated.Inspect())
+			}
+		case errorMessage:
+			testErrorObject(t, evaluated, string(expected))
+		}
+	}
+}
+
+func TestStackTraces(t *testing.T) {
+	input := `let inner = fn(x) {
+  x + true
+};
+let outer = fn() {
+  inner(1)
+};
+outer();`
+
+	evaluated := testEval(input)
+	errObj, ok := evaluated.(*object.Error)
+	if !ok {
+		t.Fatalf("object is not Error. got=%T (%+v)", evaluated, evaluated)
+	}
+
+	expected := []object.StackFrame{
+		{Function: "inner", Line: 5, Column: 8},
+		{Function: "outer", Line: 7, Column: 6},
+	}
+	if len(errObj.Stack) != len(expected) {
+		t.Fatalf("wrong number of frames. want=%v, got=%v", expected, errObj.Stack)
+	}
+	for i, frame := range expected {
+		if errObj.Stack[i] != frame {
+			t.Errorf("wrong frame %d. want=%v, got=%v", i, frame, errObj.Stack[i])
+		}
+	}
+
+	evaluated = testEval("let f = fn() { 1 + true };\nlet g = fn() { try { f() } catch (e) { throw e } };\ng()")
+	errObj, ok = evaluated.(*object.Error)
+	if !ok {
+		t.Fatalf("object is not Error. got=%T (%+v)", evaluated, evaluated)
+	}
+	expectedTrace := "ERROR: type mismatch: INTEGER + BOOLEAN\n  at f (line 2, column 23)\n  at g (line 3, column 2)"
+	if errObj.Kind != "" || errObj.Traceback() != expectedTrace {
+		t.Errorf("a rethrown error should keep its kind and stack. got kind %q and %q", errObj.Kind, errObj.Traceback())
+	}
+
+	evaluated = testEval("let g = fn([x, y]) { x + y };\nlet h = fn() { g(1) };\nh()")
+	errObj, ok = evaluated.(*object.Error)
+	if !ok {
+		t.Fatalf("object is not Error. got=%T (%+v)", evaluated, evaluated)
+	}
+	if got := fmt.Sprint(errObj.Stack); got != "[g (line 2, column 17) h (line 3, column 2)]" {
+		t.Errorf("errors binding arguments should include the callee's frame. got=%s", got)
+	}
+
+	evaluated = testEval(`let f = fn() { 1 + true }; map([1], fn(x) { f() })`)
+	errObj, ok = evaluated.(*object.Error)
+	if !ok {
+		t.Fatalf("object is not Error. got=%T (%+v)", evaluated, evaluated)
+	}
+	if got := fmt.Sprint(errObj.Stack); got != "[f (line 1, column 46) <anonymous>]" {
+		t.Errorf("wrong stack through a builtin. got=%s", got)
+	}
+}
+
+func TestModules(t *testing.T) {
+	base := t.TempDir()
+	dir := filepath.Join(base, "project")
+	lib := filepath.Join(base, "lib")
+	files := map[string]string{
+		filepath.Join(base, "secret.txt"):         `let stolen = "secret";`,
+		filepath.Join(base, "outside", "evil.mk"): `export let stolen = "secret";`,
+		filepath.Join(dir, "math.mk"): `puts("loading math");
+let square = fn(x) { x * x };
+let secret = 42;
+export let cube = fn(x) { square(x) * x };
+export let [one, two] = [1, 2];`,
+		filepath.Join(dir, "util", "strings.mk"): `let m = import "math"; export let shout = fn(s) { s + "!" }; export let nine = m["cube"](2) + m["one"];`,
+		filepath.Join(dir, "a.mk"):               `export let fromB = import "b";`,
+		filepath.Join(dir, "b.mk"):               `export let fromA = import "a";`,
+		filepath.Join(dir, "main.mk"):            `export let c = import "c";`,
+		filepath.Join(dir, "c.mk"):               `export let fromMain = import "main";`,
+		filepath.Join(dir, "util", "up.mk"):      `export let m = import "../math";`,
+		filepath.Join(dir, "util", "loop.mk"):    `import "../main";`,
+		filepath.Join(dir, "broken.mk"):          `let = 1;`,
+		filepath.Join(dir, "failing.mk"):         `export let x = 1 + true;`,
+		filepath.Join(dir, "nested.mk"):          `if (true) { export let x = 1; }`,
+		filepath.Join(lib, "greet.mk"):           `export let hello = fn(name) { "hello " + name };`,
+	}
+	for path, source := range files {
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(source), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := os.Symlink(filepath.Join(base, "outside", "evil.mk"), filepath.Join(dir, "link.mk")); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		input    string
+		expected interface{}
+	}{
+		{`let m = import "math"; m["cube"](3)`, 27},
+		{`let m = import "math.mk"; m["two"]`, 2},
+		{`let m = import "math"; m["square"]`, nil},
+		{`let m = import "math"; keys(m)`, "[cube, one, two]"},
+		{`len(import "math")`, 3},
+		{`values(import "math")[1:]`, "[1, 2]"},
+		{`entries(import "math")[1]`, "[one, 1]"},
+		{`has(import "math", "one")`, "true"},
+		{`has(import "math", "square")`, "false"},
+		{`keys(delete(import "math", "cube"))`, "[one, two]"},
+		{`merge(import "math", {"one": 10})["one"]`, 10},
+		{`json_stringify(delete(import "math", "cube"))`, `{"one":1,"two":2}`},
+		{`let m = import "math"; delete(m, "cube"); keys(m)`, "[cube, one, two]"},
+		{`let {cube, one} = import "math"; cube(one + 1)`, 8},
+		{`match (import "math") { {one: 1, ...} => "matched", _ => "no" }`, "matched"},
+		{`let s = import "util/strings"; s["shout"]("hi")`, "hi!"},
+		{`let s = import "util/strings"; s["nine"]`, 9},
+		{`import "math"; import "./math.mk"; import "util/strings"; 1`, 1},
+		{`let g = import "greet"; g["hello"]("bob")`, "hello bob"},
+		{`import "missing"`, errorMessage("module not found: missing")},
+		{`import "broken"`, errorMessage(`cannot parse module "broken"`)},
+		{`import "../secret.txt"`, errorMessage(`module path "../secret.txt" leads outside of the module root and search path`)},
+		{`import "util/../../secret.txt"`, errorMessage(`module path "util/../../secret.txt" leads outside of the module root and search path`)},
+		{`import "../outside/evil"`, errorMessage(`module path "../outside/evil" leads outside of the module root and search path`)},
+		{`let u = import "util/up"; u["m"]["two"]`, 2},
+		{`let g = import "../lib/greet"; g["hello"]("ann")`, "hello ann"},
+		{`import "util/strings"`, "module util/strings.mk"},
+		{`import "greet"`, "module greet.mk"},
+		{`import "` + filepath.Join(base, "outside", "evil.mk") + `"`, errorMessage(`module path "` + filepath.Join(base, "outside", "evil.mk") + `" must be relative`)},
+		{`import "link"`, errorMessage(`module path "link" leads outside of the module root and search path`)},
+		{`try { import "../secret.txt" } catch (e) { 0 }; stolen`, errorMessage("identifier not found: stolen")},
+		{`import "failing"`, errorMessage("type mismatch: INTEGER + BOOLEAN")},
+		{`import "nested"`, errorMessage(`cannot parse module "nested"`)},
+		{`import "a"`, errorMessage("import cycle: a.mk -> b.mk -> a.mk")},
+		{`import "c"`, errorMessage("import cycle: main.mk -> c.mk -> main.mk")},
+		{`import "util/loop"`, errorMessage("import cycle: main.mk -> util/loop.mk -> main.mk")},
+		{`try { import "missing" } catch (e) { e["message"] }`, "module not found: missing"},
+	}
+
+	for _, tt := range tests {
+		var out bytes.Buffer
+		env := object.NewEnvironment()
+		env.SetPath(filepath.Join(dir, "main.mk"))
+		env.Runtime().Stdout = &out
+		env.Runtime().ModulePaths = []string{lib, dir}
+
+		evaluated := testEvalInEnv(tt.input, env)
+		switch expected := tt.expected.(type) {
+		case int:
+			testIntegerObject(t, evaluated, int64(expected))
+		case string:
+			if evaluated.Inspect() != expected {
+				t.Errorf("%s: wrong result. want=%s, got=%s", tt.input, expected, evaluated.Inspect())
+			}
+		case errorMessage:
+			testErrorObject(t, evaluated, string(expected))
+		default:
+			testNullObject(t, evaluated)
+		}
+
+		if strings.Count(out.String(), "loading math") > 1 {
+			t.Errorf("%s: module evaluated more than once. got=%q", tt.input, out.String())
+		}
+	}
+
+	env := object.NewEnvironment()
+	if res := testEvalInEnv(`import "math"`, env); !isError(res) {
+		t.Errorf("modules should not be found without a file or search path. got=%s", res.Inspect())
+	}
+
+	env = object.NewEnvironment()
+	env.SetPath(filepath.Join(dir, "main.mk"))
+	env.Runtime().Deterministic = true
+	testErrorObject(t, testEvalInEnv(`import "math"`, env), "`import` is not available in deterministic mode")
+}
+
+func TestLimitErrorsAreNotCaught(t *testing.T) {
+	var out bytes.Buffer
+	env := object.NewEnvironment()
+	env.Runtime().Stdout = &out
+	env.Runtime().MaxDepth = 10
+
+	res := testEvalInEnv(`let f = fn() { f() }; try { f() } catch (e) { "caught" } finally { puts("finally") }`, env)
+	testErrorKind(t, res, object.DEPTH_LIMIT_ERROR)
+
+	if out.Len() != 0 {
+		t.Errorf("finally should not run after a limit error. got=%q", out.String())
+	}
+}
+
+func TestFunctionNames(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"let add = fn(a, b) { a + b }; add", "fn add(a, b) {\n(a + b)\n}"},
+		{"let add = fn(a, b) { a + b }; let plus = add; plus", "fn add(a, b) {\n(a + b)\n}"},
+		{"fn(a) { a }", "fn(a) {\na\n}"},
+	}
+
+	for _, tt := range tests {
+		res := testEval(tt.input)
+		if res.Inspect() != tt.expected {
+			t.Errorf("wrong inspect. want=%q, got=%q", tt.expected, res.Inspect())
+		}
+	}
+}
+
 func TestClosures(t *testing.T) {
 	input := `
 let newAdder = fn(x) {
@@ -269,6 +749,223 @@ let newAdder = fn(x) {
 let addTwo = newAdder(2);
 addTwo(2);`
 	testIntegerObject(t, testEval(input), 4)
+
+	// bindings are found however many scopes away they are
+	nested := `
+let base = 1;
+let outer = fn(a) {
+  fn(b) {
+    fn(c = base) { base + a + b + c };
+  };
+};
+outer(10)(100)()`
+	testIntegerObject(t, testEval(nested), 112)
+}
+
+func TestExecutionLimits(t *testing.T) {
+	env := object.NewEnvironment()
+	res := testEvalInEnv(`let f = fn() { f() }; f()`, env)
+	if testErrorObject(t, res, fmt.Sprintf("maximum call depth exceeded: %d", DefaultMaxDepth)) {
+		testErrorKind(t, res, object.DEPTH_LIMIT_ERROR)
+	}
+	if env.Runtime().Depth != 0 {
+		t.Errorf("depth not unwound. got=%d", env.Runtime().Depth)
+	}
+
+	env = object.NewEnvironment()
+	env.Runtime().MaxDepth = 3
+	testIntegerObject(t, testEvalInEnv(`let f = fn(n) { n == 0 ? 0 : f(n - 1) }; f(2)`, env), 0)
+	res = testEvalInEnv(`f(3)`, env)
+	if testErrorObject(t, res, "maximum call depth exceeded: 3") {
+		testErrorKind(t, res, object.DEPTH_LIMIT_ERROR)
+	}
+
+	env = object.NewEnvironment()
+	env.Runtime().MaxSteps = 100
+	res = testEvalInEnv(`let f = fn(n) { f(n + 1) }; f(0)`, env)
+	if testErrorObject(t, res, "step limit exceeded: 100") {
+		testErrorKind(t, res, object.STEP_LIMIT_ERROR)
+	}
+
+	env = object.NewEnvironment()
+	env.Runtime().MaxSteps = 100
+	res = testEvalInEnv(`map([1, 2, 3, 4, 5, 6, 7, 8, 9, 10], fn(x) { map([1, 2, 3, 4, 5], fn(y) { x * y }) })`, env)
+	testErrorKind(t, res, object.STEP_LIMIT_ERROR)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	env = object.NewEnvironment()
+	env.Runtime().Context = ctx
+	res = testEvalInEnv(`1 + 1`, env)
+	if testErrorObject(t, res, "evaluation canceled: context canceled") {
+		testErrorKind(t, res, object.CANCELED_ERROR)
+	}
+}
+
+func TestMemoryLimits(t *testing.T) {
+	tests := []struct {
+		input    string
+		budget   int64
+		expected interface{}
+	}{
+		{`let grow = fn(arr) { grow(push(arr, 1)) }; grow([])`, 10000, errorMessage("memory limit exceeded: 10000 bytes")},
+		{`repeat("abc", 1000000000)`, 10000, errorMessage("memory limit exceeded: 10000 bytes")},
+		{`let s = "ab"; s + s + s`, 10000, "ababab"},
+		{`[1, 2, 3][1:]`, 100, errorMessage("memory limit exceeded: 100 bytes")},
+		{`len(repeat("abc", 100))`, 10000, 300},
+		{`let a = []; push(a, 1); push(a, 2); push(a, 3)`, 120, errorMessage("memory limit exceeded: 120 bytes")},
+		{`let a = [1]; reduce(a, push, a); reduce(a, push, a); len(a)`, 10000, 4},
+		{`let a = [1]; ` + strings.Repeat(`reduce(a, push, a); `, 20) + `len(a)`, 10000, errorMessage("memory limit exceeded: 10000 bytes")},
+	}
+
+	for _, tt := range tests {
+		env := object.NewEnvironment()
+		env.Runtime().MaxMemory = tt.budget
+		res := testEvalInEnv(tt.input, env)
+
+		switch expected := tt.expected.(type) {
+		case errorMessage:
+			if testErrorObject(t, res, string(expected)) {
+				testErrorKind(t, res, object.MEMORY_LIMIT_ERROR)
+			}
+		case string:
+			testStringObject(t, res, expected)
+		case int:
+			testIntegerObject(t, res, int64(expected))
+		}
+	}
+
+	env := object.NewEnvironment()
+	testEvalInEnv(`let xs = [1, 2, 3]; let name = "monkey"`, env)
+	before := env.Runtime().Memory
+	testEvalInEnv(`xs; name`, env)
+	if env.Runtime().Memory != before {
+		t.Errorf("referring to existing objects should not allocate. got=%d, want=%d", env.Runtime().Memory, before)
+	}
+
+	testEvalInEnv(`repeat(name, 10)`, env)
+	if used := env.Runtime().Memory - before; used < 60 {
+		t.Errorf("builtin results should be accounted for. got=%d bytes", used)
+	}
+
+	// builtins building nested results must account for every container
+	// and string they create, not only the outer one
+	testEvalInEnv(`let text = "a,b,c,d"; let h = {[1, 2]: "x", [3, 4]: "y"}; let json = json_stringify([[1, 2], {"a": [3]}])`, env)
+	for _, input := range []string{`split(text, ",")`, `keys(h)`, `entries(h)`, `zip(xs, xs)`, `json_parse(json)`} {
+		before := env.Runtime().Memory
+		res := testEvalInEnv(input, env)
+		arr, ok := res.(*object.Array)
+		if !ok {
+			t.Fatalf("%s: object is not Array. got=%T (%+v)", input, res, res)
+		}
+
+		min := sizeOf(arr)
+		for _, el := range arr.Elements {
+			min += sizeOf(el)
+		}
+		if used := env.Runtime().Memory - before; used < min {
+			t.Errorf("%s: nested results should be accounted for. got=%d bytes, want at least %d", input, used, min)
+		}
+	}
+
+	root := t.TempDir()
+	if err := os.WriteFile(filepath.Join(root, "big.txt"), []byte(strings.Repeat("x", 1000)), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	env = object.NewEnvironment()
+	env.Runtime().FileRoot = root
+	env.Runtime().MaxMemory = 500
+	res := testEvalInEnv(`read_file("big.txt")`, env)
+	if testErrorObject(t, res, "memory limit exceeded: 500 bytes") {
+		testErrorKind(t, res, object.MEMORY_LIMIT_ERROR)
+	}
+}
+
+func TestGasMetering(t *testing.T) {
+	tests := []struct {
+		input    string
+		costs    *object.GasCosts
+		expected int64
+	}{
+		{`1 + 2`, nil, 5},
+		{`len("a")`, nil, 17},
+		{`let f = fn(x) { x }; f(1)`, nil, 19},
+		{`1 + 2`, &object.GasCosts{Nodes: map[string]int64{"InfixExpression": 100}}, 100},
+		{`len("a")`, &object.GasCosts{Builtins: map[string]int64{"len": 7}, DefaultNode: 1}, 12},
+		{`join(["a", "b", "c"], "")`, &object.GasCosts{PerUnit: map[string]int64{"join": 10}}, 60},
+		{`repeat("ab", 3)`, &object.GasCosts{PerUnit: map[string]int64{"repeat": 1}}, 8},
+	}
+
+	for _, tt := range tests {
+		env := object.NewEnvironment()
+		env.Runtime().GasLimit = 1000
+		env.Runtime().GasCosts = tt.costs
+		if res := testEvalInEnv(tt.input, env); isError(res) {
+			t.Errorf("%s: unexpected error %s", tt.input, res.Inspect())
+			continue
+		}
+
+		if env.Runtime().GasUsed != tt.expected {
+			t.Errorf("%s: wrong gas used. want=%d, got=%d", tt.input, tt.expected, env.Runtime().GasUsed)
+		}
+	}
+
+	// the default table charges for the size of what builtins work on
+	gasFor := func(xs string, input string) int64 {
+		used := func(input string) int64 {
+			env := object.NewEnvironment()
+			env.Runtime().GasLimit = 1 << 40
+			testEvalInEnv("let xs = "+xs+"; "+input, env)
+			return env.Runtime().GasUsed
+		}
+		return used(input) - used("0")
+	}
+	elements := make([]string, 1000)
+	for i := range elements {
+		elements[i] = fmt.Sprint(i)
+	}
+	for _, builtin := range []string{"sort(xs)", `join(map(xs, str), ",")`, "json_stringify(xs)", `repeat("ab", len(xs))`} {
+		small := gasFor("[1, 2]", builtin)
+		large := gasFor("["+strings.Join(elements, ", ")+"]", builtin)
+		if large < small+1000 {
+			t.Errorf("%s: gas should grow with the input. got=%d for 2 elements and %d for 1000", builtin, small, large)
+		}
+	}
+
+	env := object.NewEnvironment()
+	env.Runtime().GasLimit = 1000
+	res := testEvalInEnv(`repeat("ab", 1000000)`, env)
+	if testErrorObject(t, res, "out of gas: limit of 1000 exceeded") {
+		testErrorKind(t, res, object.OUT_OF_GAS_ERROR)
+	}
+
+	env = object.NewEnvironment()
+	env.Runtime().GasLimit = 500
+	res = testEvalInEnv(`let f = fn(n) { f(n + 1) }; f(0)`, env)
+	if testErrorObject(t, res, "out of gas: limit of 500 exceeded") {
+		testErrorKind(t, res, object.OUT_OF_GAS_ERROR)
+	}
+
+	env = object.NewEnvironment()
+	testEvalInEnv(`1 + 2`, env)
+	if env.Runtime().GasUsed != 0 {
+		t.Errorf("gas should only be metered with a limit. got=%d", env.Runtime().GasUsed)
+	}
+}
+
+func TestDeterministicMode(t *testing.T) {
+	var out bytes.Buffer
+	env := object.NewEnvironment()
+	env.Runtime().Stdout = &out
+	env.Runtime().Deterministic = true
+
+	testErrorObject(t, testEvalInEnv(`puts("hi")`, env), "`puts` is not available in deterministic mode")
+	testErrorObject(t, testEvalInEnv(`map([1], print)`, env), "`print` is not available in deterministic mode")
+	testIntegerObject(t, testEvalInEnv(`len("hi")`, env), 2)
+
+	if out.Len() != 0 {
+		t.Errorf("nothing should be printed. got=%q", out.String())
+	}
 }
 
 func TestStringLiteral(t *testing.T) {
@@ -309,6 +1006,8 @@ func TestBuiltinFunctions(t *testing.T) {
 		{`len("")`, 0},
 		{`len("four")`, 4},
 		{`len("hello world")`, 11},
+		{`len("héllo")`, 5},
+		{`len("日本語")`, 3},
 		{`len(1)`, "argument to `len` not supported, got INTEGER"},
 		{`len("one", "two")`, "wrong number of arguments. got=2, want=1"},
 	}
@@ -331,6 +1030,379 @@ func TestBuiltinFunctions(t *testing.T) {
 	}
 }
 
+func TestHashBuiltins(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected interface{}
+	}{
+		{`keys({"b": 1, "a": 2})`, `["b", "a"]`},
+		{`keys({})`, `[]`},
+		{`values({"b": 1, "a": 2})`, `[1, 2]`},
+		{`entries({"b": 1, "a": 2})`, `[["b", 1], ["a", 2]]`},
+		{`has({"a": 1}, "a")`, true},
+		{`has({"a": 1}, "b")`, false},
+		{`has({[1, 2]: 1}, [1, 2])`, true},
+		{`delete({"a": 1, "b": 2, "c": 3}, "b")`, `{"a": 1, "c": 3}`},
+		{`delete({"a": 1}, "z")`, `{"a": 1}`},
+		{`let h = {"a": 1}; delete(h, "a"); h`, `{"a": 1}`},
+		{`merge({"a": 1, "b": 2}, {"b": 3, "c": 4})`, `{"a": 1, "b": 3, "c": 4}`},
+		{`let h = {"a": 1}; merge(h, {"a": 2}); h`, `{"a": 1}`},
+		{`len({"a": 1, "b": 2})`, 2},
+		{`let h = {[1]: "a"}; push(keys(h)[0], 2); h[[1]]`, `"a"`},
+		{`let h = {[1]: "a"}; push(entries(h)[0][0], 2); keys(h)`, `[[1]]`},
+		{`keys([1])`, errorMessage("argument to `keys` must be HASH, got ARRAY")},
+		{`values({}, {})`, errorMessage("wrong number of arguments. got=2, want=1")},
+		{`entries(1)`, errorMessage("argument to `entries` must be HASH, got INTEGER")},
+		{`has({}, fn() {})`, errorMessage("unusable as hash key: FUNCTION")},
+		{`let a = []; push(a, a); has({}, a)`, errorMessage("unusable as hash key: ARRAY")},
+		{`has("a", "a")`, errorMessage("argument to `has` must be HASH, got STRING")},
+		{`delete({})`, errorMessage("wrong number of arguments. got=1, want=2")},
+		{`merge({}, [])`, errorMessage("argument to `merge` must be HASH, got ARRAY")},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+		switch expected := tt.expected.(type) {
+		case int:
+			testIntegerObject(t, evaluated, int64(expected))
+		case bool:
+			testBooleanObject(t, evaluated, expected)
+		case string:
+			testEqualObject(t, evaluated, testEval(expected))
+		case errorMessage:
+			testErrorObject(t, evaluated, string(expected))
+		}
+	}
+}
+
+func TestHigherOrderBuiltins(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected interface{}
+	}{
+		{`map([1, 2, 3], fn(x) { x * 2 })`, `[2, 4, 6]`},
+		{`map([], fn(x) { x * 2 })`, `[]`},
+		{`map(["a", "bc"], len)`, `[1, 2]`},
+		{`let n = 10; map([1, 2], fn(x) { x + n })`, `[11, 12]`},
+		{`filter([1, 2, 3, 4], fn(x) { x > 2 })`, `[3, 4]`},
+		{`reduce([1, 2, 3, 4], fn(acc, x) { acc + x }, 0)`, 10},
+		{`reduce([], fn(acc, x) { acc + x }, 5)`, 5},
+		{`reduce(["a", "b"], fn(acc, x) { push(acc, x) }, [])`, `["a", "b"]`},
+		{`sort([3, 1, 2])`, `[1, 2, 3]`},
+		{`sort(["b", "c", "a"])`, `["a", "b", "c"]`},
+		{`let a = [3, 1]; sort(a); a`, `[3, 1]`},
+		{`sort([3, 1, 2], fn(a, b) { a > b })`, `[3, 2, 1]`},
+		{`sort([[2, "a"], [1, "b"], [2, "c"], [1, "d"]], fn(a, b) { a[0] < b[0] })`,
+			`[[1, "b"], [1, "d"], [2, "a"], [2, "c"]]`},
+		{`zip([1, 2, 3], ["a", "b"])`, `[[1, "a"], [2, "b"]]`},
+		{`zip([1], [2], [3])`, `[[1, 2, 3]]`},
+		{`map([1], 1)`, errorMessage("argument to `map` must be callable, got INTEGER")},
+		{`filter(1, fn(x) { x })`, errorMessage("argument to `filter` must be ARRAY, got INTEGER")},
+		{`map([1, true], fn(x) { -x })`, errorMessage("unknown operator: -BOOLEAN")},
+		{`reduce([1], fn(a, b) { a + b })`, errorMessage("wrong number of arguments. got=2, want=3")},
+		{`sort([1, "a"])`, errorMessage("cannot compare STRING and INTEGER")},
+		{`sort([1, 2], fn(a, b) { a + true })`, errorMessage("type mismatch: INTEGER + BOOLEAN")},
+		{`sort([1, 2], fn(a, b) { a - b })`, errorMessage("comparator passed to `sort` must return BOOLEAN, got INTEGER")},
+		{`zip([1], 2)`, errorMessage("argument to `zip` must be ARRAY, got INTEGER")},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+		switch expected := tt.expected.(type) {
+		case int:
+			testIntegerObject(t, evaluated, int64(expected))
+		case string:
+			testEqualObject(t, evaluated, testEval(expected))
+		case errorMessage:
+			testErrorObject(t, evaluated, string(expected))
+		}
+	}
+}
+
+func TestStringBuiltins(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected interface{}
+	}{
+		{`split("a,b,c", ",")`, `["a", "b", "c"]`},
+		{`split("abc", "")`, `["a", "b", "c"]`},
+		{`split("日本", "")`, `["日", "本"]`},
+		{`join(["a", "b", "c"], "-")`, `"a-b-c"`},
+		{`join([], "-")`, `""`},
+		{`trim("  hi 	")`, `"hi"`},
+		{`upper("héllo")`, `"HÉLLO"`},
+		{`lower("HeLLo")`, `"hello"`},
+		{`contains("monkey", "key")`, true},
+		{`contains("monkey", "donkey")`, false},
+		{`starts_with("monkey", "mon")`, true},
+		{`ends_with("monkey", "mon")`, false},
+		{`replace("a-b-c", "-", "+")`, `"a+b+c"`},
+		{`index_of("monkey", "key")`, 3},
+		{`index_of("日本語", "語")`, 2},
+		{`index_of("monkey", "z")`, -1},
+		{`repeat("ab", 3)`, `"ababab"`},
+		{`repeat("ab", 0)`, `""`},
+		{`format("%s is %d", "x", 5)`, `"x is 5"`},
+		{`format("%v and %v", [1, 2], true)`, `"[1, 2] and true"`},
+		{`format("100%%")`, `"100%"`},
+		{`split(1, ",")`, errorMessage("argument to `split` must be STRING, got INTEGER")},
+		{`join([1], ",")`, errorMessage("argument to `join` must be ARRAY of STRING, got INTEGER element")},
+		{`upper("a", "b")`, errorMessage("wrong number of arguments. got=2, want=1")},
+		{`replace("a", "b")`, errorMessage("wrong number of arguments. got=2, want=3")},
+		{`repeat("a", -1)`, errorMessage("argument to `repeat` must not be negative, got -1")},
+		{`repeat("ab", 9223372036854775807)`, errorMessage("result of `repeat` is too large: 9223372036854775807 times 2 bytes")},
+		{`repeat("", 9223372036854775807)`, `""`},
+		{`format("%d", "x")`, errorMessage("%d expects INTEGER, got STRING")},
+		{`format("%s %s", "x")`, errorMessage("missing argument for %s in format string")},
+		{`format("%s", "x", "y")`, errorMessage("too many arguments for format string. got=2, want=1")},
+		{`format("%q", "x")`, errorMessage("unknown verb %q in format string")},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+		switch expected := tt.expected.(type) {
+		case int:
+			testIntegerObject(t, evaluated, int64(expected))
+		case bool:
+			testBooleanObject(t, evaluated, expected)
+		case string:
+			testEqualObject(t, evaluated, testEval(expected))
+		case errorMessage:
+			testErrorObject(t, evaluated, string(expected))
+		}
+	}
+}
+
+func TestConversionBuiltins(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected interface{}
+	}{
+		{`type(1)`, `"INTEGER"`},
+		{`type("a")`, `"STRING"`},
+		{`type(true)`, `"BOOLEAN"`},
+		{`type([])`, `"ARRAY"`},
+		{`type({})`, `"HASH"`},
+		{`type(fn() {})`, `"FUNCTION"`},
+		{`type(len)`, `"BUILTIN"`},
+		{`type(if (false) { 1 })`, `"NULL"`},
+		{`str(12)`, `"12"`},
+		{`str("a")`, `"a"`},
+		{`str([1, "a"])`, `"[1, a]"`},
+		{`int("42")`, 42},
+		{`int(" -7 ")`, -7},
+		{`int(3)`, 3},
+		{`int(true)`, 1},
+		{`bool(0)`, true},
+		{`bool("")`, true},
+		{`bool(false)`, false},
+		{`bool(if (false) { 1 })`, false},
+		{`int("4x")`, errorMessage(`cannot convert "4x" to INTEGER`)},
+		{`int([])`, errorMessage("argument to `int` not supported, got ARRAY")},
+		{`type()`, errorMessage("wrong number of arguments. got=0, want=1")},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+		switch expected := tt.expected.(type) {
+		case int:
+			testIntegerObject(t, evaluated, int64(expected))
+		case bool:
+			testBooleanObject(t, evaluated, expected)
+		case string:
+			testEqualObject(t, evaluated, testEval(expected))
+		case errorMessage:
+			testErrorObject(t, evaluated, string(expected))
+		}
+	}
+}
+
+func TestInspectBuiltin(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{`inspect("a")`, `"a"`},
+		{`inspect(5)`, `5`},
+		{`inspect(["a", 1, {"k": "v"}])`, `["a", 1, {"k": "v"}]`},
+	}
+
+	for _, tt := range tests {
+		testStringObject(t, testEval(tt.input), tt.expected)
+	}
+}
+
+func TestJSONBuiltins(t *testing.T) {
+	tests := []struct {
+		json     string
+		input    string
+		expected interface{}
+	}{
+		{`{"b": 1, "a": [true, null, "x"]}`, `json_parse(json)`, `{"b": 1, "a": [true, if (false) { 1 }, "x"]}`},
+		{`{"b": 1, "a": 2}`, `keys(json_parse(json))`, `["b", "a"]`},
+		{`[1, 2.5, -3]`, `type(json_parse(json)[1])`, `"FLOAT"`},
+		{`2.5`, `str(json_parse(json))`, `"2.5"`},
+		{`"héllo"`, `json_parse(json)`, `"héllo"`},
+		{`{"b": [1, {"c": null}], "a": "x"}`, `json_stringify(json_parse(json)) == json`, false},
+		{`{"b":[1,{"c":null}],"a":"x"}`, `json_stringify(json_parse(json)) == json`, true},
+		{`{"b":1,"a":{"d":2,"c":3}}`, `json_stringify(json_parse(json), {"sort_keys": true})`,
+			jsonOutput(`{"a":{"c":3,"d":2},"b":1}`)},
+		{`{"a":[1,2]}`, `json_stringify(json_parse(json), {"indent": 2})`,
+			jsonOutput("{\n  \"a\": [\n    1,\n    2\n  ]\n}")},
+		{`"<tag> & \"quote\""`, `json_stringify(json_parse(json))`, jsonOutput(`"<tag> & \"quote\""`)},
+		{``, `json_stringify([1, "a", true, {}])`, jsonOutput(`[1,"a",true,{}]`)},
+		{`[1] [2]`, `json_parse(json)`, errorMessage("invalid JSON: unexpected data after the top-level value")},
+		{``, `json_stringify(fn(x) { x })`, errorMessage("cannot convert FUNCTION to JSON")},
+		{``, `let a = [1]; push(a, a); json_stringify(a)`, errorMessage("cannot convert cyclic structure to JSON")},
+		{``, `let a = [1]; json_stringify([a, a])`, jsonOutput(`[[1],[1]]`)},
+		{``, `json_stringify({1: "a"})`, errorMessage("cannot convert hash with INTEGER key to JSON, keys must be STRING")},
+		{``, `json_stringify([1], {"indent": true})`, errorMessage("option `indent` must be INTEGER or STRING, got BOOLEAN")},
+		{``, `json_stringify([1], {"pretty": true})`, errorMessage("unknown `json_stringify` option pretty")},
+		{``, `json_parse(1)`, errorMessage("argument to `json_parse` must be STRING, got INTEGER")},
+	}
+
+	for _, tt := range tests {
+		env := object.NewEnvironment()
+		env.Store("json", &object.String{Value: tt.json})
+		evaluated := testEvalInEnv(tt.input, env)
+
+		switch expected := tt.expected.(type) {
+		case bool:
+			testBooleanObject(t, evaluated, expected)
+		case string:
+			testEqualObject(t, evaluated, testEval(expected))
+		case jsonOutput:
+			testStringObject(t, evaluated, string(expected))
+		case errorMessage:
+			testErrorObject(t, evaluated, string(expected))
+		}
+	}
+
+	// the wording of syntax errors comes from encoding/json, so only the
+	// prefix is checked
+	for _, input := range []string{`{"a": }`, `[1`, ``, `{1: 2}`} {
+		env := object.NewEnvironment()
+		env.Store("json", &object.String{Value: input})
+		evaluated := testEvalInEnv("json_parse(json)", env)
+
+		errObj, ok := evaluated.(*object.Error)
+		if !ok || !strings.HasPrefix(errObj.Message, "invalid JSON: ") {
+			t.Errorf("expected invalid JSON error for %q. got=%T (%+v)", input, evaluated, evaluated)
+		}
+	}
+}
+
+func TestFileSystemBuiltins(t *testing.T) {
+	root := t.TempDir()
+	outside := t.TempDir()
+	if err := os.WriteFile(filepath.Join(outside, "secret.txt"), []byte("secret"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Mkdir(filepath.Join(root, "data"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Symlink(outside, filepath.Join(root, "escape")); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Symlink(filepath.Join(outside, "planted.txt"), filepath.Join(root, "dangling")); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Symlink(filepath.Join(outside, "missing"), filepath.Join(root, "dangling_dir")); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		input    string
+		expected interface{}
+	}{
+		{`exists("data/a.txt")`, false},
+		{`write_file("data/a.txt", "héllo")`, nil},
+		{`exists("data/a.txt")`, true},
+		{`read_file("data/a.txt")`, `"héllo"`},
+		{`read_file("/data/a.txt")`, `"héllo"`},
+		{`read_file("../data/a.txt")`, `"héllo"`},
+		{`read_file("data/../../../data/a.txt")`, `"héllo"`},
+		{`write_file("data/b.txt", "b"); list_dir("data")`, `["a.txt", "b.txt"]`},
+		{`remove("data/b.txt"); list_dir("data")`, `["a.txt"]`},
+		{`read_file("missing.txt")`, errorMessage(`cannot read file "missing.txt": no such file or directory`)},
+		{`read_file("escape/secret.txt")`, errorMessage(`path "escape/secret.txt" is outside of the file system root`)},
+		{`write_file("escape/new.txt", "x")`, errorMessage(`path "escape/new.txt" is outside of the file system root`)},
+		{`write_file("dangling", "x")`, errorMessage(`path "dangling" goes through a dangling symlink`)},
+		{`read_file("dangling")`, errorMessage(`path "dangling" goes through a dangling symlink`)},
+		{`write_file("dangling_dir/new.txt", "x")`, errorMessage(`path "dangling_dir/new.txt" goes through a dangling symlink`)},
+		{`remove("/")`, errorMessage("cannot remove the file system root")},
+		{`read_file(1)`, errorMessage("argument to `read_file` must be STRING, got INTEGER")},
+		{`write_file("a.txt")`, errorMessage("wrong number of arguments. got=1, want=2")},
+	}
+
+	env := object.NewEnvironment()
+	env.Runtime().FileRoot = root
+	for _, tt := range tests {
+		evaluated := testEvalInEnv(tt.input, env)
+		switch expected := tt.expected.(type) {
+		case bool:
+			testBooleanObject(t, evaluated, expected)
+		case string:
+			testEqualObject(t, evaluated, testEval(expected))
+		case errorMessage:
+			testErrorObject(t, evaluated, string(expected))
+		default:
+			testNullObject(t, evaluated)
+		}
+	}
+
+	for _, name := range []string{"new.txt", "planted.txt"} {
+		if _, err := os.Stat(filepath.Join(outside, name)); err == nil {
+			t.Errorf("write_file escaped the file system root to create %s", name)
+		}
+	}
+
+	// without a root every file system builtin is disabled
+	for _, input := range []string{`read_file("a")`, `write_file("a", "b")`, `list_dir("")`, `exists("a")`, `remove("a")`} {
+		testErrorObject(t, testEval(input), "file system access is disabled")
+	}
+}
+
+func TestOutputBuiltins(t *testing.T) {
+	tests := []struct {
+		input          string
+		expectedStdout string
+		expectedStderr string
+	}{
+		{`puts("a", 1)`, "a\n1\n", ""},
+		{`print("a", 1); print([1, 2])`, "a1[1, 2]", ""},
+		{`eprint("oops", 1)`, "", "oops1"},
+		{`puts("out"); eprint("err"); print("!")`, "out\n!", "err"},
+		{`map([1, 2], puts)`, "1\n2\n", ""},
+	}
+
+	for _, tt := range tests {
+		var stdout, stderr bytes.Buffer
+		env := object.NewEnvironment()
+		env.Runtime().Stdout = &stdout
+		env.Runtime().Stderr = &stderr
+
+		testEvalInEnv(tt.input, env)
+		if stdout.String() != tt.expectedStdout {
+			t.Errorf("wrong stdout for %q. want=%q, got=%q", tt.input, tt.expectedStdout, stdout.String())
+		}
+		if stderr.String() != tt.expectedStderr {
+			t.Errorf("wrong stderr for %q. want=%q, got=%q", tt.input, tt.expectedStderr, stderr.String())
+		}
+	}
+}
+
+func TestBuiltinsCopy(t *testing.T) {
+	copied := Builtins()
+	copied["len"].Nondeterministic = true
+	copied["len"].Fn = nil
+
+	if builtins["len"].Nondeterministic || builtins["len"].Fn == nil {
+		t.Fatalf("changing a builtin returned by Builtins changed the default")
+	}
+	testIntegerObject(t, testEval(`len("abc")`), 3)
+}
+
 func TestArrayLiterals(t *testing.T) {
 	input := "[1, 2 * 2, 3 + 3]"
 	evaluated := testEval(input)
@@ -392,6 +1464,14 @@ func TestArrayIndexExpressions(t *testing.T) {
 		},
 		{
 			"[1, 2, 3][-1]",
+			3,
+		},
+		{
+			"[1, 2, 3][-3]",
+			1,
+		},
+		{
+			"[1, 2, 3][-4]",
 			nil,
 		},
 	}
@@ -406,6 +1486,59 @@ func TestArrayIndexExpressions(t *testing.T) {
 	}
 }
 
+func TestSliceExpressions(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected interface{}
+	}{
+		{"[1, 2, 3, 4][1:3]", []int64{2, 3}},
+		{"[1, 2, 3, 4][:2]", []int64{1, 2}},
+		{"[1, 2, 3, 4][2:]", []int64{3, 4}},
+		{"[1, 2, 3, 4][:]", []int64{1, 2, 3, 4}},
+		{"[1, 2, 3, 4][-2:]", []int64{3, 4}},
+		{"[1, 2, 3, 4][:-1]", []int64{1, 2, 3}},
+		{"[1, 2, 3, 4][3:1]", []int64{}},
+		{"[1, 2, 3, 4][1:100]", []int64{2, 3, 4}},
+		{"let a = [1, 2, 3]; let b = a[0:2]; push(b, 9); a", []int64{1, 2, 3}},
+		{`"hello"[1:3]`, "el"},
+		{`"hello"[-3:]`, "llo"},
+		{`"hello"[:0]`, ""},
+		{`"hello"[-1]`, "o"},
+		{`"hello"[5]`, nil},
+		{`"日本語"[1]`, "本"},
+		{`"日本語"[-1]`, "語"},
+		{`"héllo"[1:3]`, "él"},
+		{`let café = "☕"; café`, "☕"},
+		{`[1, 2]["a":]`, errorMessage("slice index must be INTEGER, got STRING")},
+		{`5[1:]`, errorMessage("slice operator not supported: INTEGER")},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+		switch expected := tt.expected.(type) {
+		case []int64:
+			arr, ok := evaluated.(*object.Array)
+			if !ok {
+				t.Errorf("object is not Array. got=%T (%+v)", evaluated, evaluated)
+				continue
+			}
+			if len(arr.Elements) != len(expected) {
+				t.Errorf("wrong num of elements. want=%d, got=%d", len(expected), len(arr.Elements))
+				continue
+			}
+			for i, el := range expected {
+				testIntegerObject(t, arr.Elements[i], el)
+			}
+		case string:
+			testStringObject(t, evaluated, expected)
+		case errorMessage:
+			testErrorObject(t, evaluated, string(expected))
+		default:
+			testNullObject(t, evaluated)
+		}
+	}
+}
+
 func TestHashLiterals(t *testing.T) {
 	input := `let two = "two";
 {
@@ -421,25 +1554,47 @@ false: 6
 	if !ok {
 		t.Fatalf("Eval didn't return Hash. got=%T (%+v)", evaluated, evaluated)
 	}
-	expected := map[object.HashKey]int64{
-		(&object.String{Value: "one"}).HashKey():   1,
-		(&object.String{Value: "two"}).HashKey():   2,
-		(&object.String{Value: "three"}).HashKey(): 3,
-		(&object.Integer{Value: 4}).HashKey():      4,
-		TRUE.HashKey():                             5,
-		FALSE.HashKey():                            6,
+	expected := []struct {
+		key   object.Object
+		value int64
+	}{
+		{&object.String{Value: "one"}, 1},
+		{&object.String{Value: "two"}, 2},
+		{&object.String{Value: "three"}, 3},
+		{&object.Integer{Value: 4}, 4},
+		{TRUE, 5},
+		{FALSE, 6},
 	}
 
-	if len(result.Pairs) != len(expected) {
-		t.Fatalf("Hash has wrong num of pairs. got=%d", len(result.Pairs))
+	if result.Len() != len(expected) {
+		t.Fatalf("Hash has wrong num of pairs. got=%d", result.Len())
 	}
 
-	for expectedKey, expectedValue := range expected {
-		pair, ok := result.Pairs[expectedKey]
+	for _, tt := range expected {
+		value, ok := result.Get(tt.key)
 		if !ok {
-			t.Errorf("no pair for given key in Pairs")
+			t.Errorf("no pair for given key %s in Pairs", tt.key.Inspect())
+			continue
+		}
+		testIntegerObject(t, value, tt.value)
+	}
+}
+
+func TestHashInspectOrder(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{`{"b": 1, "a": 2, "c": 3}`, "{b: 1, a: 2, c: 3}"},
+		{`{3: "x", 1: "y", 2: "z", 1: "w"}`, "{3: x, 1: w, 2: z}"},
+		{`{[2]: {"z": 1, "y": 2}, [1]: true}`, "{[2]: {z: 1, y: 2}, [1]: true}"},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+		if evaluated.Inspect() != tt.expected {
+			t.Errorf("wrong Inspect output. want=%q, got=%q", tt.expected, evaluated.Inspect())
 		}
-		testIntegerObject(t, pair.Value, expectedValue)
 	}
 }
 
@@ -476,6 +1631,38 @@ func TestHashIndexExpressions(t *testing.T) {
 			`{false: 5}[false]`,
 			5,
 		},
+		{
+			`{true: 5}["true"]`,
+			nil,
+		},
+		{
+			`{1: 5}[true]`,
+			nil,
+		},
+		{
+			`{[1, 2]: 5}[[1, 2]]`,
+			5,
+		},
+		{
+			`{[1, 2]: 5}[[2, 1]]`,
+			nil,
+		},
+		{
+			`{[1, [2, "a"]]: 5}[[1, [2, "a"]]]`,
+			5,
+		},
+		{
+			`{{"a": 1, "b": 2}: 5}[{"b": 2, "a": 1}]`,
+			5,
+		},
+		{
+			`let key = [1]; let h = {key: 5}; push(key, 2); h[[1]]`,
+			5,
+		},
+		{
+			`{[1]: 5, [1]: 6}[[1]]`,
+			6,
+		},
 	}
 	for _, tt := range tests {
 		evaluated := testEval(tt.input)
@@ -488,6 +1675,66 @@ func TestHashIndexExpressions(t *testing.T) {
 	}
 }
 
+// jsonOutput marks an expected value in table tests as the exact content of
+// a string result
+type jsonOutput string
+
+// errorMessage marks an expected value in table tests as an error message
+// rather than a string result
+type errorMessage string
+
+func testStringObject(t *testing.T, obj object.Object, expected string) bool {
+	result, ok := obj.(*object.String)
+	if !ok {
+		t.Errorf("object is not String. got=%T (%+v)", obj, obj)
+		return false
+	}
+
+	if result.Value != expected {
+		t.Errorf("object has wrong value. got=%q, want=%q", result.Value, expected)
+		return false
+	}
+	return true
+}
+
+// testEqualObject checks obj against the result of evaluating the expected
+// source, which keeps expected arrays and hashes readable
+func testEqualObject(t *testing.T, obj object.Object, expected object.Object) bool {
+	if obj == nil || !obj.Equals(expected) {
+		t.Errorf("object has wrong value. got=%v, want=%s", obj, expected.Inspect())
+		return false
+	}
+	return true
+}
+
+func testErrorObject(t *testing.T, obj object.Object, expected string) bool {
+	errObj, ok := obj.(*object.Error)
+	if !ok {
+		t.Errorf("object is not Error. got=%T (%+v)", obj, obj)
+		return false
+	}
+
+	if errObj.Message != expected {
+		t.Errorf("wrong error message. expected=%q, got=%q", expected, errObj.Message)
+		return false
+	}
+	return true
+}
+
+func testErrorKind(t *testing.T, obj object.Object, expected object.ErrorKind) bool {
+	errObj, ok := obj.(*object.Error)
+	if !ok {
+		t.Errorf("object is not Error. got=%T (%+v)", obj, obj)
+		return false
+	}
+
+	if errObj.Kind != expected {
+		t.Errorf("wrong error kind. expected=%q, got=%q", expected, errObj.Kind)
+		return false
+	}
+	return true
+}
+
 func testNullObject(t *testing.T, obj object.Object) bool {
 	if obj != NULL {
 		t.Errorf("object is not NULL. got=%T (%+v)", obj, obj)
@@ -512,10 +1759,13 @@ func testBooleanObject(t *testing.T, obj object.Object, expected bool) bool {
 }
 
 func testEval(input string) object.Object {
+	return testEvalInEnv(input, object.NewEnvironment())
+}
+
+func testEvalInEnv(input string, env *object.Environment) object.Object {
 	l := lexer.NewLexer(input)
 	p := parser.NewParser(l)
 	program := p.ParseProgram()
-	env := object.NewEnvironment()
 	return Eval(program, env)
 }
 
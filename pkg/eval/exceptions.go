@@ -0,0 +1,100 @@
+package eval
+
+import (
+	"github.com/AhmedThresh/not-even-a-compiler/pkg/ast"
+	"github.com/AhmedThresh/not-even-a-compiler/pkg/object"
+)
+
+// throwError turns the value of a throw statement into an error. Strings
+// become the message, as does the "message" of a hash. A caught error being
+// thrown again keeps its kind and stack.
+func throwError(value object.Object) *object.Error {
+	err := &object.Error{Kind: object.THROWN_ERROR, Value: value}
+
+	switch value := value.(type) {
+	case *object.String:
+		err.Message = value.Value
+	case *object.Hash:
+		if caught := value.Caught(); caught != nil {
+			return rethrownError(caught)
+		}
+
+		if message, ok := value.Get(&object.String{Value: "message"}); ok && message.Type() == object.STRING {
+			err.Message = message.(*object.String).Value
+		} else {
+			err.Message = value.Inspect()
+		}
+	default:
+		err.Message = value.Inspect()
+	}
+	return err
+}
+
+func evalTryExpression(node *ast.TryExpression, env *object.Environment) object.Object {
+	res := Eval(node.Block, env)
+
+	if err, ok := res.(*object.Error); ok && err.Catchable() && node.Catch != nil {
+		catchEnv := object.NewEnclosedEnvironment(env)
+		if node.CatchParam != nil {
+			caught := caughtError(err)
+			if limit := allocate(env.Runtime(), environmentSize+bindingSize+sizeOf(caught)); limit != nil {
+				return limit
+			}
+			catchEnv.Store(node.CatchParam.Value, caught)
+		}
+		res = Eval(node.Catch, catchEnv)
+	}
+
+	// Limit errors end the evaluation right away, finally blocks included
+	if err, ok := res.(*object.Error); ok && !err.Catchable() {
+		return err
+	}
+
+	if node.Finally != nil {
+		// The finally block only changes the outcome when it fails or
+		// returns
+		final := Eval(node.Finally, env)
+		if isError(final) || (final != nil && final.Type() == object.RETURN_VALUE_OBJ) {
+			return final
+		}
+	}
+
+	if res == nil {
+		return NULL
+	}
+	return res
+}
+
+// rethrownError copies the error a catch block was given, so that frames
+// added while it unwinds again don't change the stack of the original
+func rethrownError(caught *object.Error) *object.Error {
+	err := *caught
+	err.Stack = append([]object.StackFrame{}, caught.Stack...)
+	return &err
+}
+
+// caughtError is the value a catch block sees for err
+func caughtError(err *object.Error) *object.Hash {
+	kind := string(err.Kind)
+	if kind == "" {
+		kind = "RUNTIME"
+	}
+
+	stack := make([]object.Object, len(err.Stack))
+	for i, frame := range err.Stack {
+		stack[i] = &object.String{Value: frame.String()}
+	}
+
+	value := err.Value
+	if value == nil {
+		value = NULL
+	}
+
+	caught := object.NewHash()
+	caught.Set(&object.String{Value: "message"}, &object.String{Value: err.Message})
+	caught.Set(&object.String{Value: "kind"}, &object.String{Value: kind})
+	caught.Set(&object.String{Value: "stack"}, &object.Array{Elements: stack})
+	caught.Set(&object.String{Value: "value"}, value)
+	caught.SetCaught(err)
+	return caught
+}
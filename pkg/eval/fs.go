@@ -0,0 +1,167 @@
+package eval
+
+import (
+	"errors"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/AhmedThresh/not-even-a-compiler/pkg/object"
+)
+
+// resolvePath maps a script supplied path onto the host file system. Paths
+// are always interpreted relative to the runtime's FileRoot, and the result
+// is rejected if following symlinks would lead outside of the root.
+func resolvePath(runtime *object.Runtime, name string) (string, *object.Error) {
+	if runtime == nil || runtime.FileRoot == "" {
+		return "", newError("file system access is disabled")
+	}
+
+	root, err := filepath.EvalSymlinks(runtime.FileRoot)
+	if err != nil {
+		return "", newError("file system root is unavailable: %s", unwrapPathError(err))
+	}
+
+	// cleaning the path as if it were absolute drops every leading ".."
+	resolved := filepath.Join(root, filepath.FromSlash(path.Clean("/"+name)))
+
+	// the target itself may not exist yet, so check the deepest existing
+	// ancestor instead. A dangling symlink looks like a missing file but
+	// writing through it would create its target, wherever that is.
+	existing := resolved
+	for {
+		real, err := filepath.EvalSymlinks(existing)
+		if err == nil {
+			if !isWithin(root, real) {
+				return "", newError("path %q is outside of the file system root", name)
+			}
+			break
+		}
+
+		if !errors.Is(err, fs.ErrNotExist) {
+			return "", newError("cannot resolve path %q: %s", name, unwrapPathError(err))
+		}
+
+		if info, err := os.Lstat(existing); err == nil && info.Mode()&fs.ModeSymlink != 0 {
+			return "", newError("path %q goes through a dangling symlink", name)
+		}
+
+		parent := filepath.Dir(existing)
+		if parent == existing {
+			break
+		}
+		existing = parent
+	}
+
+	return resolved, nil
+}
+
+func isWithin(root string, target string) bool {
+	rel, err := filepath.Rel(root, target)
+	if err != nil {
+		return false
+	}
+	return rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator))
+}
+
+// unwrapPathError drops the host path from file system errors so that the
+// location of the root is not exposed to scripts
+func unwrapPathError(err error) error {
+	var pathErr *fs.PathError
+	if errors.As(err, &pathErr) {
+		return pathErr.Err
+	}
+	return err
+}
+
+func readFile(runtime *object.Runtime, name string) object.Object {
+	resolved, errObj := resolvePath(runtime, name)
+	if errObj != nil {
+		return errObj
+	}
+
+	// refuse files that cannot fit in the memory budget before reading them
+	info, err := os.Stat(resolved)
+	if err != nil {
+		return newError("cannot read file %q: %s", name, unwrapPathError(err))
+	}
+	if err := checkAllocation(runtime, 1, info.Size()); err != nil {
+		return err
+	}
+
+	content, err := os.ReadFile(resolved)
+	if err != nil {
+		return newError("cannot read file %q: %s", name, unwrapPathError(err))
+	}
+	return &object.String{Value: string(content)}
+}
+
+func writeFile(runtime *object.Runtime, name string, content string) object.Object {
+	resolved, errObj := resolvePath(runtime, name)
+	if errObj != nil {
+		return errObj
+	}
+
+	if err := os.WriteFile(resolved, []byte(content), 0o644); err != nil {
+		return newError("cannot write file %q: %s", name, unwrapPathError(err))
+	}
+	return NULL
+}
+
+func listDir(runtime *object.Runtime, name string) object.Object {
+	resolved, errObj := resolvePath(runtime, name)
+	if errObj != nil {
+		return errObj
+	}
+
+	entries, err := os.ReadDir(resolved)
+	if err != nil {
+		return newError("cannot list directory %q: %s", name, unwrapPathError(err))
+	}
+
+	names := make([]string, len(entries))
+	for i, entry := range entries {
+		names[i] = entry.Name()
+	}
+	sort.Strings(names)
+
+	elements := make([]object.Object, len(names))
+	for i, name := range names {
+		elements[i] = &object.String{Value: name}
+	}
+	res := &object.Array{Elements: elements}
+	if err := allocateContents(runtime, res); err != nil {
+		return err
+	}
+	return res
+}
+
+func pathExists(runtime *object.Runtime, name string) object.Object {
+	resolved, errObj := resolvePath(runtime, name)
+	if errObj != nil {
+		return errObj
+	}
+
+	_, err := os.Stat(resolved)
+	return nativeBoolToBooleanObject(err == nil)
+}
+
+func removePath(runtime *object.Runtime, name string) object.Object {
+	resolved, errObj := resolvePath(runtime, name)
+	if errObj != nil {
+		return errObj
+	}
+
+	root, _ := filepath.EvalSymlinks(runtime.FileRoot)
+	if resolved == root {
+		return newError("cannot remove the file system root")
+	}
+
+	if err := os.Remove(resolved); err != nil {
+		return newError("cannot remove %q: %s", name, unwrapPathError(err))
+	}
+	return NULL
+}
@@ -0,0 +1,206 @@
+package eval
+
+import (
+	"reflect"
+
+	"github.com/AhmedThresh/not-even-a-compiler/pkg/ast"
+	"github.com/AhmedThresh/not-even-a-compiler/pkg/object"
+)
+
+var defaultGasCosts = object.GasCosts{
+	Nodes: map[string]int64{
+		"Program":               0,
+		"ExpressionStatement":   0,
+		"BlockStatement":        0,
+		"LetStatement":          1,
+		"ReturnStatement":       1,
+		"IntegerLiteral":        1,
+		"StringLiteral":         1,
+		"Boolean":               1,
+		"Identifier":            1,
+		"PrefixExpression":      2,
+		"InfixExpression":       3,
+		"IfExpression":          2,
+		"ConditionalExpression": 2,
+		"Array":                 2,
+		"HashLiteral":           5,
+		"IndexExpression":       2,
+		"SliceExpression":       5,
+		"MatchExpression":       2,
+		"TryExpression":         2,
+		"ImportExpression":      10,
+		"ThrowStatement":        1,
+		"FunctionLiteral":       5,
+		"CallExpression":        10,
+	},
+	Builtins: map[string]int64{
+		"map":            10,
+		"filter":         10,
+		"reduce":         10,
+		"sort":           20,
+		"split":          10,
+		"join":           10,
+		"replace":        10,
+		"format":         10,
+		"json_parse":     20,
+		"json_stringify": 20,
+		"read_file":      50,
+		"write_file":     50,
+		"list_dir":       50,
+		"exists":         50,
+		"remove":         50,
+	},
+	PerUnit: map[string]int64{
+		"rest":           1,
+		"keys":           1,
+		"values":         1,
+		"entries":        1,
+		"delete":         1,
+		"merge":          1,
+		"map":            1,
+		"filter":         1,
+		"reduce":         1,
+		"sort":           2,
+		"zip":            1,
+		"split":          1,
+		"join":           1,
+		"trim":           1,
+		"upper":          1,
+		"lower":          1,
+		"contains":       1,
+		"starts_with":    1,
+		"ends_with":      1,
+		"replace":        1,
+		"index_of":       1,
+		"repeat":         1,
+		"format":         1,
+		"str":            1,
+		"inspect":        1,
+		"json_parse":     1,
+		"json_stringify": 1,
+		"read_file":      1,
+		"write_file":     1,
+		"puts":           1,
+		"print":          1,
+		"eprint":         1,
+	},
+	DefaultNode:    1,
+	DefaultBuiltin: 5,
+}
+
+// DefaultGasCosts returns a copy of the cost table used when the runtime
+// doesn't provide one
+func DefaultGasCosts() *object.GasCosts {
+	costs := defaultGasCosts
+	costs.Nodes = make(map[string]int64, len(defaultGasCosts.Nodes))
+	for kind, cost := range defaultGasCosts.Nodes {
+		costs.Nodes[kind] = cost
+	}
+
+	costs.Builtins = make(map[string]int64, len(defaultGasCosts.Builtins))
+	for name, cost := range defaultGasCosts.Builtins {
+		costs.Builtins[name] = cost
+	}
+
+	costs.PerUnit = make(map[string]int64, len(defaultGasCosts.PerUnit))
+	for name, cost := range defaultGasCosts.PerUnit {
+		costs.PerUnit[name] = cost
+	}
+	return &costs
+}
+
+func gasCosts(runtime *object.Runtime) *object.GasCosts {
+	if runtime.GasCosts != nil {
+		return runtime.GasCosts
+	}
+	return &defaultGasCosts
+}
+
+// chargeNode meters the evaluation of node, the kind of a node is the name
+// of its type in package ast
+func chargeNode(runtime *object.Runtime, node ast.Node) *object.Error {
+	if runtime.GasLimit <= 0 {
+		return nil
+	}
+
+	costs := gasCosts(runtime)
+	cost, ok := costs.Nodes[reflect.TypeOf(node).Elem().Name()]
+	if !ok {
+		cost = costs.DefaultNode
+	}
+	return chargeGas(runtime, cost)
+}
+
+// chargeBuiltin meters a call of builtin with args, builtins priced per unit
+// pay for the size of their arguments up front
+func chargeBuiltin(runtime *object.Runtime, builtin *object.Builtin, args []object.Object) *object.Error {
+	if runtime.GasLimit <= 0 {
+		return nil
+	}
+
+	costs := gasCosts(runtime)
+	cost, ok := costs.Builtins[builtin.Name]
+	if !ok {
+		cost = costs.DefaultBuiltin
+	}
+
+	if perUnit := costs.PerUnit[builtin.Name]; perUnit > 0 {
+		for _, arg := range args {
+			cost += perUnit * gasUnits(arg)
+		}
+	}
+	return chargeGas(runtime, cost)
+}
+
+// chargeBuiltinResult meters the size of what builtin returned, which is
+// only known once it ran
+func chargeBuiltinResult(runtime *object.Runtime, builtin *object.Builtin, res object.Object) *object.Error {
+	if runtime.GasLimit <= 0 {
+		return nil
+	}
+
+	perUnit := gasCosts(runtime).PerUnit[builtin.Name]
+	if perUnit <= 0 {
+		return nil
+	}
+	return chargeGas(runtime, perUnit*gasUnits(res))
+}
+
+// checkGas reports the out of gas error when a result of units units built
+// by the builtin name would not be affordable, without charging for it.
+// Builtins producing much more than they are given call it before doing
+// the work.
+func checkGas(runtime *object.Runtime, name string, units int64) *object.Error {
+	if runtime.GasLimit <= 0 {
+		return nil
+	}
+
+	perUnit := gasCosts(runtime).PerUnit[name]
+	if perUnit > 0 && units > (runtime.GasLimit-runtime.GasUsed)/perUnit {
+		return newKindError(object.OUT_OF_GAS_ERROR, "out of gas: limit of %d exceeded", runtime.GasLimit)
+	}
+	return nil
+}
+
+// gasUnits measures obj for per unit pricing, without the objects it
+// contains
+func gasUnits(obj object.Object) int64 {
+	switch obj := exportsOf(obj).(type) {
+	case *object.String:
+		return int64(len(obj.Value))
+	case *object.Array:
+		return int64(len(obj.Elements))
+	case *object.Hash:
+		return int64(obj.Len())
+	default:
+		return 0
+	}
+}
+
+func chargeGas(runtime *object.Runtime, cost int64) *object.Error {
+	runtime.GasUsed += cost
+	if runtime.GasUsed > runtime.GasLimit {
+		return newKindError(object.OUT_OF_GAS_ERROR, "out of gas: limit of %d exceeded", runtime.GasLimit)
+	}
+	return nil
+}
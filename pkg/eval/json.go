@@ -0,0 +1,239 @@
+package eval
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"io"
+	"math"
+	"sort"
+	"strings"
+
+	"github.com/AhmedThresh/not-even-a-compiler/pkg/object"
+)
+
+// jsonOptions holds the settings accepted by `json_stringify`
+type jsonOptions struct {
+	indent   string
+	sortKeys bool
+}
+
+// parseJSON decodes a single JSON document. Objects keep the order of their
+// keys, whole numbers become INTEGER and every other number becomes FLOAT.
+func parseJSON(input string) object.Object {
+	dec := json.NewDecoder(strings.NewReader(input))
+	dec.UseNumber()
+
+	res, err := decodeJSONValue(dec)
+	if err != nil {
+		return newError("invalid JSON: %s", err)
+	}
+
+	if _, err := dec.Token(); err != io.EOF {
+		return newError("invalid JSON: unexpected data after the top-level value")
+	}
+
+	return res
+}
+
+func decodeJSONValue(dec *json.Decoder) (object.Object, error) {
+	tok, err := dec.Token()
+	if err != nil {
+		if err == io.EOF {
+			return nil, io.ErrUnexpectedEOF
+		}
+		return nil, err
+	}
+
+	switch tok := tok.(type) {
+	case json.Delim:
+		switch tok {
+		case '[':
+			elements := []object.Object{}
+			for dec.More() {
+				el, err := decodeJSONValue(dec)
+				if err != nil {
+					return nil, err
+				}
+				elements = append(elements, el)
+			}
+			// consume the closing bracket
+			if _, err := dec.Token(); err != nil {
+				return nil, err
+			}
+			return &object.Array{Elements: elements}, nil
+		case '{':
+			hash := object.NewHash()
+			for dec.More() {
+				key, err := dec.Token()
+				if err != nil {
+					return nil, err
+				}
+
+				value, err := decodeJSONValue(dec)
+				if err != nil {
+					return nil, err
+				}
+				hash.Set(&object.String{Value: key.(string)}, value)
+			}
+			// consume the closing brace
+			if _, err := dec.Token(); err != nil {
+				return nil, err
+			}
+			return hash, nil
+		}
+	case string:
+		return &object.String{Value: tok}, nil
+	case bool:
+		return nativeBoolToBooleanObject(tok), nil
+	case json.Number:
+		if i, err := tok.Int64(); err == nil {
+			return &object.Integer{Value: i}, nil
+		}
+		f, err := tok.Float64()
+		if err != nil {
+			return nil, err
+		}
+		return &object.Float{Value: f}, nil
+	case nil:
+		return NULL, nil
+	}
+
+	return nil, errors.New("unexpected token")
+}
+
+// stringifyJSON encodes obj as JSON, keeping the insertion order of hash keys
+// unless sorting was requested
+func stringifyJSON(obj object.Object, options jsonOptions) object.Object {
+	var out bytes.Buffer
+	if err := encodeJSONValue(&out, obj, options, map[object.Object]bool{}); err != nil {
+		return err
+	}
+
+	if options.indent == "" {
+		return &object.String{Value: out.String()}
+	}
+
+	var pretty bytes.Buffer
+	if err := json.Indent(&pretty, out.Bytes(), "", options.indent); err != nil {
+		return newError("cannot indent JSON: %s", err)
+	}
+	return &object.String{Value: pretty.String()}
+}
+
+// encodeJSONValue writes obj to out, encoding holds the arrays and hashes
+// being written so that a container holding itself is reported instead of
+// being encoded forever
+func encodeJSONValue(out *bytes.Buffer, obj object.Object, options jsonOptions, encoding map[object.Object]bool) *object.Error {
+	obj = exportsOf(obj)
+	switch obj.(type) {
+	case *object.Array, *object.Hash:
+		if encoding[obj] {
+			return newError("cannot convert cyclic structure to JSON")
+		}
+		encoding[obj] = true
+		defer delete(encoding, obj)
+	}
+
+	switch obj := obj.(type) {
+	case *object.Null:
+		out.WriteString("null")
+	case *object.Boolean, *object.Integer:
+		out.WriteString(obj.Inspect())
+	case *object.Float:
+		if math.IsNaN(obj.Value) || math.IsInf(obj.Value, 0) {
+			return newError("cannot convert %s to JSON", obj.Inspect())
+		}
+		encoded, _ := json.Marshal(obj.Value)
+		out.Write(encoded)
+	case *object.String:
+		writeJSONString(out, obj.Value)
+	case *object.Array:
+		out.WriteByte('[')
+		for i, el := range obj.Elements {
+			if i > 0 {
+				out.WriteByte(',')
+			}
+			if err := encodeJSONValue(out, el, options, encoding); err != nil {
+				return err
+			}
+		}
+		out.WriteByte(']')
+	case *object.Hash:
+		pairs := obj.Pairs()
+		for _, pair := range pairs {
+			if pair.Key.Type() != object.STRING {
+				return newError("cannot convert hash with %s key to JSON, keys must be STRING", pair.Key.Type())
+			}
+		}
+
+		if options.sortKeys {
+			sort.SliceStable(pairs, func(i, j int) bool {
+				return pairs[i].Key.(*object.String).Value < pairs[j].Key.(*object.String).Value
+			})
+		}
+
+		out.WriteByte('{')
+		for i, pair := range pairs {
+			if i > 0 {
+				out.WriteByte(',')
+			}
+			writeJSONString(out, pair.Key.(*object.String).Value)
+			out.WriteByte(':')
+			if err := encodeJSONValue(out, pair.Value, options, encoding); err != nil {
+				return err
+			}
+		}
+		out.WriteByte('}')
+	default:
+		return newError("cannot convert %s to JSON", obj.Type())
+	}
+
+	return nil
+}
+
+func writeJSONString(out *bytes.Buffer, value string) {
+	enc := json.NewEncoder(out)
+	enc.SetEscapeHTML(false)
+	enc.Encode(value)
+	// Encode terminates every value with a newline
+	out.Truncate(out.Len() - 1)
+}
+
+// jsonOptionsFromHash reads the optional settings hash of `json_stringify`:
+// "indent" is either a number of spaces or the indentation string itself and
+// "sort_keys" orders object keys alphabetically
+func jsonOptionsFromHash(hash *object.Hash) (jsonOptions, *object.Error) {
+	options := jsonOptions{}
+
+	for _, pair := range hash.Pairs() {
+		key, ok := pair.Key.(*object.String)
+		if !ok {
+			return options, newError("unknown `json_stringify` option %s", pair.Key.Inspect())
+		}
+
+		switch key.Value {
+		case "indent":
+			switch indent := pair.Value.(type) {
+			case *object.Integer:
+				if indent.Value < 0 {
+					return options, newError("option `indent` must not be negative, got %d", indent.Value)
+				}
+				options.indent = strings.Repeat(" ", int(indent.Value))
+			case *object.String:
+				options.indent = indent.Value
+			default:
+				return options, newError("option `indent` must be INTEGER or STRING, got %s", pair.Value.Type())
+			}
+		case "sort_keys":
+			if pair.Value.Type() != object.BOOLEAN {
+				return options, newError("option `sort_keys` must be BOOLEAN, got %s", pair.Value.Type())
+			}
+			options.sortKeys = pair.Value == TRUE
+		default:
+			return options, newError("unknown `json_stringify` option %s", key.Value)
+		}
+	}
+
+	return options, nil
+}
@@ -0,0 +1,57 @@
+package eval
+
+import (
+	"github.com/AhmedThresh/not-even-a-compiler/pkg/ast"
+	"github.com/AhmedThresh/not-even-a-compiler/pkg/object"
+)
+
+// DefaultMaxDepth is the call depth limit used when the runtime doesn't set
+// one. It keeps runaway recursion well clear of the Go stack limit.
+const DefaultMaxDepth = 10000
+
+// step accounts for the evaluation of node and reports the error ending the
+// evaluation once the runtime's context is done, or its step budget or gas
+// is spent
+func step(runtime *object.Runtime, node ast.Node) *object.Error {
+	runtime.Steps++
+	if runtime.MaxSteps > 0 && runtime.Steps > runtime.MaxSteps {
+		return newKindError(object.STEP_LIMIT_ERROR, "step limit exceeded: %d", runtime.MaxSteps)
+	}
+
+	if err := chargeNode(runtime, node); err != nil {
+		return err
+	}
+
+	if runtime.Context != nil {
+		if err := runtime.Context.Err(); err != nil {
+			return newKindError(object.CANCELED_ERROR, "evaluation canceled: %s", err)
+		}
+	}
+	return nil
+}
+
+// enterCall increases the call depth, leaveCall must follow unless an error
+// is returned
+func enterCall(runtime *object.Runtime) *object.Error {
+	maxDepth := runtime.MaxDepth
+	if maxDepth <= 0 {
+		maxDepth = DefaultMaxDepth
+	}
+
+	if runtime.Depth >= maxDepth {
+		return newKindError(object.DEPTH_LIMIT_ERROR, "maximum call depth exceeded: %d", maxDepth)
+	}
+
+	runtime.Depth++
+	return nil
+}
+
+func leaveCall(runtime *object.Runtime) {
+	runtime.Depth--
+}
+
+func newKindError(kind object.ErrorKind, format string, a ...interface{}) *object.Error {
+	err := newError(format, a...)
+	err.Kind = kind
+	return err
+}
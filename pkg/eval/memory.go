@@ -0,0 +1,118 @@
+package eval
+
+import (
+	"github.com/AhmedThresh/not-even-a-compiler/pkg/object"
+)
+
+// Approximate sizes in bytes of the objects created while evaluating. They
+// only need to be in the right ballpark to stop a script hoarding memory.
+const (
+	valueSize       = 16 // an interface value, and an Integer or Float
+	stringSize      = 16 // plus one byte per byte of content
+	arraySize       = 24 // plus one interface value per element
+	hashSize        = 48 // plus hashPairSize per pair
+	hashPairSize    = 64
+	functionSize    = 64
+	environmentSize = 48 // plus bindingSize per binding
+	bindingSize     = 32
+)
+
+// sizeOf estimates the memory obj occupies itself, without the objects it
+// refers to, as those were accounted for when they were created. Hashes own
+// the copies of their array and hash keys, so those are included.
+func sizeOf(obj object.Object) int64 {
+	switch obj := obj.(type) {
+	case *object.Integer, *object.Float, *object.ReturnValue:
+		return valueSize
+	case *object.String:
+		return stringSize + int64(len(obj.Value))
+	case *object.Array:
+		return arraySize + valueSize*int64(len(obj.Elements))
+	case *object.Hash:
+		size := hashSize + hashPairSize*int64(obj.Len())
+		for _, pair := range obj.Pairs() {
+			size += sizeOfKeyCopy(pair.Key)
+		}
+		return size
+	case *object.Function:
+		return functionSize
+	default:
+		return 0
+	}
+}
+
+// sizeOfTree estimates the memory of obj and everything it contains, each
+// object being counted once. It is meant for values built in Go, such as
+// parsed JSON, whose contents were never accounted for.
+func sizeOfTree(obj object.Object) int64 {
+	return sizeOfTreeIn(obj, map[object.Object]bool{})
+}
+
+func sizeOfTreeIn(obj object.Object, counted map[object.Object]bool) int64 {
+	if counted[obj] {
+		return 0
+	}
+	counted[obj] = true
+
+	size := sizeOf(obj)
+	switch obj := obj.(type) {
+	case *object.Array:
+		for _, el := range obj.Elements {
+			size += sizeOfTreeIn(el, counted)
+		}
+	case *object.Hash:
+		for _, pair := range obj.Pairs() {
+			size += sizeOfTreeIn(pair.Value, counted)
+		}
+	}
+	return size
+}
+
+// sizeOfKeyCopy estimates the memory of the copy object.CopyKey makes of
+// key, only arrays and hashes are copied
+func sizeOfKeyCopy(key object.Object) int64 {
+	switch key.(type) {
+	case *object.Array, *object.Hash:
+		return sizeOfTree(key)
+	default:
+		return 0
+	}
+}
+
+// allocate adds size bytes to the runtime's memory usage and reports the
+// error ending the evaluation once the memory budget is exceeded
+func allocate(runtime *object.Runtime, size int64) *object.Error {
+	runtime.Memory += size
+	if runtime.MaxMemory > 0 && runtime.Memory > runtime.MaxMemory {
+		return newKindError(object.MEMORY_LIMIT_ERROR, "memory limit exceeded: %d bytes", runtime.MaxMemory)
+	}
+	return nil
+}
+
+// checkAllocation reports the memory limit error when count items of size
+// bytes don't fit in what is left of the budget, without accounting for them
+func checkAllocation(runtime *object.Runtime, size int64, count int64) *object.Error {
+	if runtime.MaxMemory <= 0 || size == 0 {
+		return nil
+	}
+
+	if count > (runtime.MaxMemory-runtime.Memory)/size {
+		return newKindError(object.MEMORY_LIMIT_ERROR, "memory limit exceeded: %d bytes", runtime.MaxMemory)
+	}
+	return nil
+}
+
+// allocateContents accounts for everything obj contains but not obj itself,
+// which is tracked when a builtin returns it. All of it must be new.
+func allocateContents(runtime *object.Runtime, obj object.Object) *object.Error {
+	return allocate(runtime, sizeOfTree(obj)-sizeOf(obj))
+}
+
+// track accounts for a newly created object and returns it, or the memory
+// limit error
+func track(runtime *object.Runtime, obj object.Object) object.Object {
+	if err := allocate(runtime, sizeOf(obj)); err != nil {
+		return err
+	}
+	return obj
+}
@@ -0,0 +1,250 @@
+package eval
+
+import (
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+
+	"github.com/AhmedThresh/not-even-a-compiler/pkg/ast"
+	"github.com/AhmedThresh/not-even-a-compiler/pkg/lexer"
+	"github.com/AhmedThresh/not-even-a-compiler/pkg/object"
+	"github.com/AhmedThresh/not-even-a-compiler/pkg/parser"
+)
+
+// ModuleExtension is appended to import paths without an extension
+const ModuleExtension = ".mk"
+
+func evalExportStatement(node *ast.ExportStatement, env *object.Environment) object.Object {
+	if !env.TopLevel() {
+		return newError("export is only allowed at the top level of a module")
+	}
+
+	res := Eval(node.Statement, env)
+	if isError(res) {
+		return res
+	}
+
+	if node.Statement.Pattern != nil {
+		for _, name := range patternNames(node.Statement.Pattern) {
+			env.Export(name)
+		}
+	} else {
+		env.Export(node.Statement.Name.Value)
+	}
+	return res
+}
+
+// patternNames lists the names a destructuring pattern binds
+func patternNames(pattern ast.Pattern) []string {
+	names := []string{}
+
+	switch pattern := pattern.(type) {
+	case *ast.Identifier:
+		names = append(names, pattern.Value)
+	case *ast.ArrayPattern:
+		for _, el := range pattern.Elements {
+			names = append(names, patternNames(el)...)
+		}
+		if pattern.Rest != nil {
+			names = append(names, pattern.Rest.Value)
+		}
+	case *ast.HashPattern:
+		for _, pair := range pattern.Pairs {
+			names = append(names, patternNames(pair.Value)...)
+		}
+		if pattern.Rest != nil {
+			names = append(names, pattern.Rest.Value)
+		}
+	}
+	return names
+}
+
+// evalImportExpression evaluates the imported module in its own environment
+// the first time it is imported, later imports share the cached module
+func evalImportExpression(node *ast.ImportExpression, env *object.Environment) object.Object {
+	runtime := env.Runtime()
+	if runtime.Deterministic {
+		return newError("`import` is not available in deterministic mode")
+	}
+
+	importing := runtime.Importing
+	chain := importing
+	if len(chain) == 0 && env.Path() != "" {
+		chain = []string{env.Path()}
+	}
+
+	// the entry file is the first of the chain, imports may reach anything
+	// below its directory
+	entryDir := ""
+	if len(chain) > 0 {
+		entryDir = filepath.Dir(chain[0])
+	}
+	roots := moduleRoots(entryDir, runtime.ModulePaths)
+
+	path, err := resolveModule(node.Path, env.Path(), runtime.ModulePaths, roots)
+	if err != nil {
+		return err
+	}
+
+	if module, ok := runtime.Modules[path]; ok {
+		return module
+	}
+
+	for i, p := range chain {
+		if p == path {
+			cycle := []string{}
+			for _, p := range chain[i:] {
+				cycle = append(cycle, moduleName(p, roots))
+			}
+			cycle = append(cycle, moduleName(path, roots))
+			return newError("import cycle: %s", strings.Join(cycle, " -> "))
+		}
+	}
+
+	runtime.Importing = append(chain, path)
+	defer func() { runtime.Importing = importing }()
+
+	module, err := loadModule(node.Path, path, env)
+	if err != nil {
+		return err
+	}
+	module.Path = moduleName(path, roots)
+
+	if runtime.Modules == nil {
+		runtime.Modules = make(map[string]*object.Module)
+	}
+	runtime.Modules[path] = module
+	return module
+}
+
+// moduleRoots returns the real paths of the directories modules are
+// confined to: the directory of the entry file, if any, and the search path
+func moduleRoots(entryDir string, searchPath []string) []string {
+	dirs := searchPath
+	if entryDir != "" {
+		dirs = append([]string{entryDir}, searchPath...)
+	}
+
+	roots := []string{}
+	for _, dir := range dirs {
+		dir, err := filepath.Abs(dir)
+		if err != nil {
+			continue
+		}
+		if root, err := filepath.EvalSymlinks(dir); err == nil {
+			roots = append(roots, root)
+		}
+	}
+	return roots
+}
+
+// resolveModule finds the file imported as spec, first next to the importing
+// file and then in the search path, and returns its real path. Modules are
+// confined to roots the same way the file system builtins are confined to
+// their root, but may import each other across them.
+func resolveModule(spec string, importer string, searchPath []string, roots []string) (string, *object.Error) {
+	if path.IsAbs(spec) || filepath.IsAbs(spec) {
+		return "", newError("module path %q must be relative", spec)
+	}
+
+	file := filepath.FromSlash(spec)
+	if filepath.Ext(file) == "" {
+		file += ModuleExtension
+	}
+
+	dirs := []string{}
+	if importer != "" {
+		dirs = append(dirs, filepath.Dir(importer))
+	}
+	dirs = append(dirs, searchPath...)
+
+	for _, dir := range dirs {
+		dir, err := filepath.Abs(dir)
+		if err != nil {
+			continue
+		}
+
+		real, err := filepath.EvalSymlinks(filepath.Join(dir, file))
+		if err != nil {
+			continue
+		}
+		if !withinAny(roots, real) {
+			return "", newError("module path %q leads outside of the module root and search path", spec)
+		}
+
+		if info, err := os.Stat(real); err != nil || info.IsDir() {
+			continue
+		}
+		return real, nil
+	}
+
+	return "", newError("module not found: %s", spec)
+}
+
+func withinAny(roots []string, target string) bool {
+	for _, root := range roots {
+		if isWithin(root, target) {
+			return true
+		}
+	}
+	return false
+}
+
+// moduleName is how the module at path is shown to scripts: relative to the
+// first root it is found in, so that host paths are not exposed
+func moduleName(path string, roots []string) string {
+	for _, root := range roots {
+		if isWithin(root, path) {
+			if rel, err := filepath.Rel(root, path); err == nil {
+				return filepath.ToSlash(rel)
+			}
+		}
+	}
+	return filepath.Base(path)
+}
+
+// loadModule parses and evaluates the module imported as spec from path,
+// exposing its exported bindings. Errors name the module by spec and leave
+// its content out, so that host paths and files are not exposed to scripts.
+func loadModule(spec string, path string, importer *object.Environment) (*object.Module, *object.Error) {
+	source, err := os.ReadFile(path)
+	if err != nil {
+		return nil, newError("cannot read module %q: %s", spec, unwrapPathError(err))
+	}
+
+	p := parser.NewParser(lexer.NewLexer(string(source)))
+	program := p.ParseProgram()
+	if len(p.Errors()) != 0 {
+		return nil, newError("cannot parse module %q", spec)
+	}
+
+	if err := allocate(importer.Runtime(), environmentSize); err != nil {
+		return nil, err
+	}
+
+	env := object.NewModuleEnvironment(importer, path)
+	if res, ok := Eval(program, env).(*object.Error); ok {
+		return nil, res
+	}
+
+	exports := object.NewHash()
+	for _, name := range env.Exports() {
+		value, _ := env.Get(name)
+		exports.Set(&object.String{Value: name}, value)
+	}
+	if err := allocate(importer.Runtime(), sizeOf(exports)); err != nil {
+		return nil, err
+	}
+
+	return &object.Module{Exports: exports}, nil
+}
+
+// exportsOf lets a module stand in for the hash of its exports, any other
+// object is returned as is
+func exportsOf(obj object.Object) object.Object {
+	if module, ok := obj.(*object.Module); ok {
+		return module.Exports
+	}
+	return obj
+}
@@ -0,0 +1,223 @@
+package eval
+
+import (
+	"github.com/AhmedThresh/not-even-a-compiler/pkg/ast"
+	"github.com/AhmedThresh/not-even-a-compiler/pkg/object"
+)
+
+// bindPattern binds value to the names in pattern, destructuring arrays and
+// hashes along the way. It returns an error when value doesn't have the
+// shape the pattern describes.
+func bindPattern(pattern ast.Pattern, value object.Object, env *object.Environment) *object.Error {
+	switch pattern := pattern.(type) {
+	case *ast.Identifier:
+		return bind(pattern.Value, value, env)
+	case *ast.ArrayPattern:
+		return bindArrayPattern(pattern, value, env)
+	case *ast.HashPattern:
+		return bindHashPattern(pattern, value, env)
+	default:
+		return newError("unknown pattern: %s", pattern)
+	}
+}
+
+func bind(name string, value object.Object, env *object.Environment) *object.Error {
+	if err := allocate(env.Runtime(), bindingSize); err != nil {
+		return err
+	}
+
+	env.Store(name, value)
+	return nil
+}
+
+func bindArrayPattern(pattern *ast.ArrayPattern, value object.Object, env *object.Environment) *object.Error {
+	arr, ok := value.(*object.Array)
+	if !ok {
+		return newError("cannot destructure %s with array pattern %s", value.Type(), pattern)
+	}
+
+	switch {
+	case !pattern.Open && len(arr.Elements) != len(pattern.Elements):
+		return newError("array pattern %s expects %d elements, got %d", pattern, len(pattern.Elements), len(arr.Elements))
+	case len(arr.Elements) < len(pattern.Elements):
+		return newError("array pattern %s expects at least %d elements, got %d", pattern, len(pattern.Elements), len(arr.Elements))
+	}
+
+	for i, el := range pattern.Elements {
+		if err := bindPattern(el, arr.Elements[i], env); err != nil {
+			return err
+		}
+	}
+
+	if pattern.Rest != nil {
+		return bindArrayRest(pattern, arr, env)
+	}
+	return nil
+}
+
+// bindArrayRest binds the elements of arr past those pattern names to its
+// rest identifier
+func bindArrayRest(pattern *ast.ArrayPattern, arr *object.Array, env *object.Environment) *object.Error {
+	rest := sliceArray(arr, len(pattern.Elements), len(arr.Elements))
+	if err := allocate(env.Runtime(), sizeOf(rest)); err != nil {
+		return err
+	}
+	return bind(pattern.Rest.Value, rest, env)
+}
+
+func bindHashPattern(pattern *ast.HashPattern, value object.Object, env *object.Environment) *object.Error {
+	hash, ok := exportsOf(value).(*object.Hash)
+	if !ok {
+		return newError("cannot destructure %s with hash pattern %s", value.Type(), pattern)
+	}
+
+	for _, pair := range pattern.Pairs {
+		v, ok := hash.Get(&object.String{Value: pair.Key})
+		if !ok {
+			return newError("hash pattern %s: key not found: %s", pattern, pair.Key)
+		}
+
+		if err := bindPattern(pair.Value, v, env); err != nil {
+			return err
+		}
+	}
+
+	if pattern.Rest != nil {
+		return bindHashRest(pattern, hash, env)
+	}
+	return nil
+}
+
+// bindHashRest binds the pairs of hash whose keys pattern doesn't name to
+// its rest identifier
+func bindHashRest(pattern *ast.HashPattern, hash *object.Hash, env *object.Environment) *object.Error {
+	taken := make(map[string]bool, len(pattern.Pairs))
+	for _, pair := range pattern.Pairs {
+		taken[pair.Key] = true
+	}
+
+	rest := object.NewHash()
+	for _, pair := range hash.Pairs() {
+		if key, ok := pair.Key.(*object.String); ok && taken[key.Value] {
+			continue
+		}
+		rest.Set(pair.Key, pair.Value)
+	}
+
+	if err := allocate(env.Runtime(), sizeOf(rest)); err != nil {
+		return err
+	}
+	return bind(pattern.Rest.Value, rest, env)
+}
+
+func evalMatchExpression(node *ast.MatchExpression, env *object.Environment) object.Object {
+	value := Eval(node.Value, env)
+	if isError(value) {
+		return value
+	}
+
+	for _, arm := range node.Arms {
+		// Each arm binds into its own environment so that a partial match
+		// doesn't leak bindings into the next arm
+		armEnv := object.NewEnclosedEnvironment(env)
+		if err := allocate(env.Runtime(), environmentSize); err != nil {
+			return err
+		}
+
+		matched, err := matchPattern(arm.Pattern, value, armEnv)
+		if err != nil {
+			return err
+		}
+		if !matched {
+			continue
+		}
+
+		if arm.Guard != nil {
+			guard := Eval(arm.Guard, armEnv)
+			if isError(guard) {
+				return guard
+			}
+			if !isTruthy(guard) {
+				continue
+			}
+		}
+
+		return Eval(arm.Body, armEnv)
+	}
+
+	return newError("no match arm matches %s", value.Inspect())
+}
+
+// matchPattern reports whether value has the shape pattern describes,
+// binding the names in pattern as it goes. The identifier _ matches any
+// value without binding it.
+func matchPattern(pattern ast.Pattern, value object.Object, env *object.Environment) (bool, *object.Error) {
+	switch pattern := pattern.(type) {
+	case *ast.Identifier:
+		if pattern.Value == "_" {
+			return true, nil
+		}
+		return true, bind(pattern.Value, value, env)
+	case *ast.LiteralPattern:
+		literal := Eval(pattern.Value, env)
+		if err, ok := literal.(*object.Error); ok {
+			return false, err
+		}
+		return literal.Equals(value), nil
+	case *ast.ArrayPattern:
+		return matchArrayPattern(pattern, value, env)
+	case *ast.HashPattern:
+		return matchHashPattern(pattern, value, env)
+	default:
+		return false, newError("unknown pattern: %s", pattern)
+	}
+}
+
+func matchArrayPattern(pattern *ast.ArrayPattern, value object.Object, env *object.Environment) (bool, *object.Error) {
+	arr, ok := value.(*object.Array)
+	if !ok {
+		return false, nil
+	}
+
+	if len(arr.Elements) < len(pattern.Elements) || (!pattern.Open && len(arr.Elements) != len(pattern.Elements)) {
+		return false, nil
+	}
+
+	for i, el := range pattern.Elements {
+		if matched, err := matchPattern(el, arr.Elements[i], env); !matched || err != nil {
+			return false, err
+		}
+	}
+
+	if pattern.Rest != nil {
+		return true, bindArrayRest(pattern, arr, env)
+	}
+	return true, nil
+}
+
+func matchHashPattern(pattern *ast.HashPattern, value object.Object, env *object.Environment) (bool, *object.Error) {
+	hash, ok := exportsOf(value).(*object.Hash)
+	if !ok {
+		return false, nil
+	}
+
+	if !pattern.Open && hash.Len() != len(pattern.Pairs) {
+		return false, nil
+	}
+
+	for _, pair := range pattern.Pairs {
+		v, ok := hash.Get(&object.String{Value: pair.Key})
+		if !ok {
+			return false, nil
+		}
+
+		if matched, err := matchPattern(pair.Value, v, env); !matched || err != nil {
+			return false, err
+		}
+	}
+
+	if pattern.Rest != nil {
+		return true, bindHashRest(pattern, hash, env)
+	}
+	return true, nil
+}
@@ -0,0 +1,499 @@
+package interpreter
+
+import (
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+
+	"github.com/AhmedThresh/not-even-a-compiler/pkg/eval"
+	"github.com/AhmedThresh/not-even-a-compiler/pkg/object"
+)
+
+// tagName is the struct tag used to rename or skip fields, e.g.
+// `monkey:"name"` or `monkey:"-"`
+const tagName = "monkey"
+
+var (
+	objectType = reflect.TypeOf((*object.Object)(nil)).Elem()
+	errorType  = reflect.TypeOf((*error)(nil)).Elem()
+)
+
+// ToObject converts a Go value into the equivalent Monkey object. Objects
+// are passed through unchanged, structs become hashes keyed by field name
+// and funcs become builtins as described by WrapFunc.
+func ToObject(value interface{}) (object.Object, error) {
+	if obj, ok := value.(object.Object); ok {
+		return obj, nil
+	}
+	return toObject(reflect.ValueOf(value))
+}
+
+func toObject(v reflect.Value) (object.Object, error) {
+	if !v.IsValid() {
+		return eval.NULL, nil
+	}
+
+	if v.Type().Implements(objectType) && !(v.Kind() == reflect.Pointer && v.IsNil()) {
+		return v.Interface().(object.Object), nil
+	}
+
+	switch v.Kind() {
+	case reflect.Bool:
+		if v.Bool() {
+			return eval.TRUE, nil
+		}
+		return eval.FALSE, nil
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return &object.Integer{Value: v.Int()}, nil
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		if v.Uint() > 1<<63-1 {
+			return nil, fmt.Errorf("cannot convert %d to INTEGER, it overflows", v.Uint())
+		}
+		return &object.Integer{Value: int64(v.Uint())}, nil
+	case reflect.Float32, reflect.Float64:
+		return &object.Float{Value: v.Float()}, nil
+	case reflect.String:
+		return &object.String{Value: v.String()}, nil
+	case reflect.Pointer, reflect.Interface:
+		if v.IsNil() {
+			return eval.NULL, nil
+		}
+		return toObject(v.Elem())
+	case reflect.Slice, reflect.Array:
+		if v.Kind() == reflect.Slice && v.IsNil() {
+			return eval.NULL, nil
+		}
+
+		elements := make([]object.Object, v.Len())
+		for i := range elements {
+			el, err := toObject(v.Index(i))
+			if err != nil {
+				return nil, err
+			}
+			elements[i] = el
+		}
+		return &object.Array{Elements: elements}, nil
+	case reflect.Map:
+		return mapToHash(v)
+	case reflect.Struct:
+		return structToHash(v)
+	case reflect.Func:
+		return wrapFunc(v)
+	default:
+		return nil, fmt.Errorf("cannot convert %s to a Monkey object", v.Type())
+	}
+}
+
+func mapToHash(v reflect.Value) (object.Object, error) {
+	if v.IsNil() {
+		return eval.NULL, nil
+	}
+
+	type entry struct {
+		key   object.Object
+		value reflect.Value
+	}
+
+	entries := make([]entry, 0, v.Len())
+	iter := v.MapRange()
+	for iter.Next() {
+		key, err := toObject(iter.Key())
+		if err != nil {
+			return nil, err
+		}
+
+		if !object.IsHashable(key) {
+			return nil, fmt.Errorf("cannot use %s as hash key", key.Type())
+		}
+		entries = append(entries, entry{key: key, value: iter.Value()})
+	}
+
+	// Go maps are unordered, sort the keys to keep the result stable
+	sort.Slice(entries, func(i, j int) bool {
+		if entries[i].key.Type() != entries[j].key.Type() {
+			return entries[i].key.Type() < entries[j].key.Type()
+		}
+		if left, ok := entries[i].key.(*object.Integer); ok {
+			return left.Value < entries[j].key.(*object.Integer).Value
+		}
+		return entries[i].key.Inspect() < entries[j].key.Inspect()
+	})
+
+	hash := object.NewHash()
+	for _, e := range entries {
+		value, err := toObject(e.value)
+		if err != nil {
+			return nil, err
+		}
+		hash.Set(e.key, value)
+	}
+	return hash, nil
+}
+
+func structToHash(v reflect.Value) (object.Object, error) {
+	hash := object.NewHash()
+	for _, field := range structFields(v.Type()) {
+		value, err := toObject(v.FieldByIndex(field.index))
+		if err != nil {
+			return nil, fmt.Errorf("field %s: %w", field.name, err)
+		}
+		hash.Set(&object.String{Value: field.name}, value)
+	}
+	return hash, nil
+}
+
+type structField struct {
+	name  string
+	index []int
+}
+
+// structFields lists the exported fields of t in declaration order under the
+// name scripts see them by
+func structFields(t reflect.Type) []structField {
+	fields := []structField{}
+	for _, field := range reflect.VisibleFields(t) {
+		if !field.IsExported() || field.Anonymous {
+			continue
+		}
+
+		name := field.Name
+		if tag, ok := field.Tag.Lookup(tagName); ok {
+			tag, _, _ = strings.Cut(tag, ",")
+			if tag == "-" {
+				continue
+			}
+			if tag != "" {
+				name = tag
+			}
+		}
+		fields = append(fields, structField{name: name, index: field.Index})
+	}
+	return fields
+}
+
+// FromObject stores the Go equivalent of obj in the value out points to,
+// the reverse of ToObject. Storing into an interface{} produces int64,
+// float64, string, bool, nil, []interface{} and map[string]interface{}.
+func FromObject(obj object.Object, out interface{}) error {
+	v := reflect.ValueOf(out)
+	if v.Kind() != reflect.Pointer || v.IsNil() {
+		return fmt.Errorf("FromObject needs a non-nil pointer, got %T", out)
+	}
+
+	res, err := fromObject(obj, v.Elem().Type(), map[object.Object]bool{})
+	if err != nil {
+		return err
+	}
+	v.Elem().Set(res)
+	return nil
+}
+
+// fromObject converts obj to t, converting holds the arrays and hashes being
+// converted so that one holding itself is reported instead of being
+// converted forever
+func fromObject(obj object.Object, t reflect.Type, converting map[object.Object]bool) (reflect.Value, error) {
+	// Objects can be handed over as they are to parameters such as
+	// object.Object or *object.Hash
+	if t.Implements(objectType) || (t.Kind() == reflect.Interface && t.NumMethod() > 0) {
+		if !reflect.TypeOf(obj).AssignableTo(t) {
+			return reflect.Value{}, fmt.Errorf("cannot convert %s to %s", obj.Type(), t)
+		}
+		return reflect.ValueOf(obj), nil
+	}
+
+	mismatch := func() (reflect.Value, error) {
+		return reflect.Value{}, fmt.Errorf("cannot convert %s to %s", obj.Type(), t)
+	}
+	cyclic := func() (reflect.Value, error) {
+		return reflect.Value{}, fmt.Errorf("cannot convert cyclic %s to %s", obj.Type(), t)
+	}
+
+	switch t.Kind() {
+	case reflect.Interface:
+		native, err := toNative(obj, converting)
+		if err != nil {
+			return reflect.Value{}, err
+		}
+		if native == nil {
+			return reflect.Zero(t), nil
+		}
+		return reflect.ValueOf(native), nil
+	case reflect.Pointer:
+		if obj.Type() == object.NULL {
+			return reflect.Zero(t), nil
+		}
+		elem, err := fromObject(obj, t.Elem(), converting)
+		if err != nil {
+			return reflect.Value{}, err
+		}
+		ptr := reflect.New(t.Elem())
+		ptr.Elem().Set(elem)
+		return ptr, nil
+	case reflect.Bool:
+		b, ok := obj.(*object.Boolean)
+		if !ok {
+			return mismatch()
+		}
+		return reflect.ValueOf(b.Value).Convert(t), nil
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		i, ok := obj.(*object.Integer)
+		if !ok {
+			return mismatch()
+		}
+		res := reflect.New(t).Elem()
+		if res.OverflowInt(i.Value) {
+			return reflect.Value{}, fmt.Errorf("%d overflows %s", i.Value, t)
+		}
+		res.SetInt(i.Value)
+		return res, nil
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		i, ok := obj.(*object.Integer)
+		if !ok {
+			return mismatch()
+		}
+		res := reflect.New(t).Elem()
+		if i.Value < 0 || res.OverflowUint(uint64(i.Value)) {
+			return reflect.Value{}, fmt.Errorf("%d overflows %s", i.Value, t)
+		}
+		res.SetUint(uint64(i.Value))
+		return res, nil
+	case reflect.Float32, reflect.Float64:
+		res := reflect.New(t).Elem()
+		switch num := obj.(type) {
+		case *object.Float:
+			res.SetFloat(num.Value)
+		case *object.Integer:
+			res.SetFloat(float64(num.Value))
+		default:
+			return mismatch()
+		}
+		return res, nil
+	case reflect.String:
+		s, ok := obj.(*object.String)
+		if !ok {
+			return mismatch()
+		}
+		return reflect.ValueOf(s.Value).Convert(t), nil
+	case reflect.Slice:
+		if obj.Type() == object.NULL {
+			return reflect.Zero(t), nil
+		}
+		arr, ok := obj.(*object.Array)
+		if !ok {
+			return mismatch()
+		}
+		if converting[obj] {
+			return cyclic()
+		}
+		converting[obj] = true
+		defer delete(converting, obj)
+
+		res := reflect.MakeSlice(t, len(arr.Elements), len(arr.Elements))
+		for i, el := range arr.Elements {
+			v, err := fromObject(el, t.Elem(), converting)
+			if err != nil {
+				return reflect.Value{}, fmt.Errorf("element %d: %w", i, err)
+			}
+			res.Index(i).Set(v)
+		}
+		return res, nil
+	case reflect.Array:
+		arr, ok := obj.(*object.Array)
+		if !ok {
+			return mismatch()
+		}
+		if len(arr.Elements) != t.Len() {
+			return reflect.Value{}, fmt.Errorf("cannot convert ARRAY of length %d to %s", len(arr.Elements), t)
+		}
+		if converting[obj] {
+			return cyclic()
+		}
+		converting[obj] = true
+		defer delete(converting, obj)
+
+		res := reflect.New(t).Elem()
+		for i, el := range arr.Elements {
+			v, err := fromObject(el, t.Elem(), converting)
+			if err != nil {
+				return reflect.Value{}, fmt.Errorf("element %d: %w", i, err)
+			}
+			res.Index(i).Set(v)
+		}
+		return res, nil
+	case reflect.Map:
+		if obj.Type() == object.NULL {
+			return reflect.Zero(t), nil
+		}
+		hash, ok := obj.(*object.Hash)
+		if !ok {
+			return mismatch()
+		}
+		if converting[obj] {
+			return cyclic()
+		}
+		converting[obj] = true
+		defer delete(converting, obj)
+
+		res := reflect.MakeMapWithSize(t, hash.Len())
+		for _, pair := range hash.Pairs() {
+			key, err := fromObject(pair.Key, t.Key(), converting)
+			if err != nil {
+				return reflect.Value{}, fmt.Errorf("key %s: %w", pair.Key.Inspect(), err)
+			}
+			value, err := fromObject(pair.Value, t.Elem(), converting)
+			if err != nil {
+				return reflect.Value{}, fmt.Errorf("key %s: %w", pair.Key.Inspect(), err)
+			}
+			res.SetMapIndex(key, value)
+		}
+		return res, nil
+	case reflect.Struct:
+		hash, ok := obj.(*object.Hash)
+		if !ok {
+			return mismatch()
+		}
+		if converting[obj] {
+			return cyclic()
+		}
+		converting[obj] = true
+		defer delete(converting, obj)
+
+		res := reflect.New(t).Elem()
+		for _, field := range structFields(t) {
+			value, ok := hash.Get(&object.String{Value: field.name})
+			if !ok {
+				continue
+			}
+			v, err := fromObject(value, res.FieldByIndex(field.index).Type(), converting)
+			if err != nil {
+				return reflect.Value{}, fmt.Errorf("field %s: %w", field.name, err)
+			}
+			res.FieldByIndex(field.index).Set(v)
+		}
+		return res, nil
+	default:
+		return mismatch()
+	}
+}
+
+// toNative converts obj into the plain Go value used for interface{} targets
+func toNative(obj object.Object, converting map[object.Object]bool) (interface{}, error) {
+	switch obj := obj.(type) {
+	case *object.Null:
+		return nil, nil
+	case *object.Boolean:
+		return obj.Value, nil
+	case *object.Integer:
+		return obj.Value, nil
+	case *object.Float:
+		return obj.Value, nil
+	case *object.String:
+		return obj.Value, nil
+	case *object.Array:
+		if converting[obj] {
+			return nil, fmt.Errorf("cannot convert cyclic ARRAY")
+		}
+		converting[obj] = true
+		defer delete(converting, obj)
+
+		res := make([]interface{}, len(obj.Elements))
+		for i, el := range obj.Elements {
+			native, err := toNative(el, converting)
+			if err != nil {
+				return nil, err
+			}
+			res[i] = native
+		}
+		return res, nil
+	case *object.Hash:
+		if converting[obj] {
+			return nil, fmt.Errorf("cannot convert cyclic HASH")
+		}
+		converting[obj] = true
+		defer delete(converting, obj)
+
+		res := make(map[string]interface{}, obj.Len())
+		for _, pair := range obj.Pairs() {
+			key, ok := pair.Key.(*object.String)
+			if !ok {
+				return nil, fmt.Errorf("cannot convert HASH with %s key to map[string]interface {}", pair.Key.Type())
+			}
+			native, err := toNative(pair.Value, converting)
+			if err != nil {
+				return nil, err
+			}
+			res[key.Value] = native
+		}
+		return res, nil
+	default:
+		return obj, nil
+	}
+}
+
+// WrapFunc turns a Go func into a builtin. Arguments are converted with
+// FromObject and checked against the parameter types, and results with
+// ToObject. A trailing error result is reported to scripts as an ERROR.
+func WrapFunc(fn interface{}) (*object.Builtin, error) {
+	return wrapFunc(reflect.ValueOf(fn))
+}
+
+func wrapFunc(fn reflect.Value) (*object.Builtin, error) {
+	if fn.Kind() != reflect.Func || fn.IsNil() {
+		return nil, fmt.Errorf("cannot wrap %s, it is not a func", fn.Type())
+	}
+
+	t := fn.Type()
+	results := t.NumOut()
+	returnsError := results > 0 && t.Out(results-1) == errorType
+	if returnsError {
+		results--
+	}
+	if results > 1 {
+		return nil, fmt.Errorf("cannot wrap %s, it returns more than one value besides an error", t)
+	}
+
+	return &object.Builtin{
+		Fn: func(ctx *object.CallContext, args ...object.Object) object.Object {
+			fixed := t.NumIn()
+			if t.IsVariadic() {
+				fixed--
+			}
+
+			if len(args) < fixed || (!t.IsVariadic() && len(args) > fixed) {
+				return &object.Error{Message: fmt.Sprintf("wrong number of arguments. got=%d, want=%d", len(args), fixed)}
+			}
+
+			in := make([]reflect.Value, len(args))
+			for i, arg := range args {
+				paramType := t.In(min(i, t.NumIn()-1))
+				if t.IsVariadic() && i >= fixed {
+					paramType = paramType.Elem()
+				}
+
+				v, err := fromObject(arg, paramType, map[object.Object]bool{})
+				if err != nil {
+					return &object.Error{Message: fmt.Sprintf("argument %d: %s", i+1, err)}
+				}
+				in[i] = v
+			}
+
+			out := fn.Call(in)
+			if returnsError {
+				if err, _ := out[len(out)-1].Interface().(error); err != nil {
+					return &object.Error{Message: err.Error()}
+				}
+			}
+
+			if results == 0 {
+				return eval.NULL
+			}
+
+			res, err := toObject(out[0])
+			if err != nil {
+				return &object.Error{Message: err.Error()}
+			}
+			return res
+		},
+	}, nil
+}
@@ -0,0 +1,227 @@
+package interpreter
+
+import (
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/AhmedThresh/not-even-a-compiler/pkg/object"
+)
+
+type address struct {
+	City string `monkey:"city"`
+	Zip  int    `monkey:"zip"`
+}
+
+type person struct {
+	Name     string   `monkey:"name"`
+	Age      int      `monkey:"age"`
+	Tags     []string `monkey:"tags"`
+	Address  *address `monkey:"address"`
+	Password string   `monkey:"-"`
+	Nickname string
+	secret   string
+}
+
+func TestToObject(t *testing.T) {
+	tests := []struct {
+		input    interface{}
+		expected string
+	}{
+		{nil, "NULL"},
+		{true, "true"},
+		{int8(-3), "-3"},
+		{uint16(7), "7"},
+		{float32(1.5), "1.5"},
+		{"hi", "hi"},
+		{[]int{1, 2, 3}, "[1, 2, 3]"},
+		{[2]bool{true, false}, "[true, false]"},
+		{[]string(nil), "NULL"},
+		{map[string]int{"b": 2, "a": 1}, "{a: 1, b: 2}"},
+		{map[int]string{10: "x", 2: "y"}, "{2: y, 10: x}"},
+		{&address{City: "Oslo", Zip: 150}, "{city: Oslo, zip: 150}"},
+		{
+			person{Name: "Ann", Age: 30, Tags: []string{"x"}, Password: "hunter2", Nickname: "A", secret: "s"},
+			"{name: Ann, age: 30, tags: [x], address: NULL, Nickname: A}",
+		},
+	}
+
+	for _, tt := range tests {
+		obj, err := ToObject(tt.input)
+		if err != nil {
+			t.Errorf("ToObject(%#v) returned an error: %s", tt.input, err)
+			continue
+		}
+
+		if obj.Inspect() != tt.expected {
+			t.Errorf("ToObject(%#v) wrong result. want=%s, got=%s", tt.input, tt.expected, obj.Inspect())
+		}
+	}
+
+	if _, err := ToObject(uint64(1 << 63)); err == nil {
+		t.Errorf("expected an error for an overflowing uint64")
+	}
+
+	if _, err := ToObject(map[[2]float64]int{{1, 2}: 3}); err == nil {
+		t.Errorf("expected an error for an unhashable key")
+	}
+}
+
+func TestFromObject(t *testing.T) {
+	i := New()
+	obj, err := i.Run(`{"name": "Ann", "age": 30, "tags": ["a", "b"], "address": {"city": "Oslo", "zip": 150}, "extra": 1}`)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var p person
+	if err := FromObject(obj, &p); err != nil {
+		t.Fatalf("FromObject returned an error: %s", err)
+	}
+
+	expected := person{Name: "Ann", Age: 30, Tags: []string{"a", "b"}, Address: &address{City: "Oslo", Zip: 150}}
+	if !reflect.DeepEqual(p, expected) {
+		t.Errorf("wrong struct. want=%+v, got=%+v", expected, p)
+	}
+
+	var native interface{}
+	if err := FromObject(obj, &native); err != nil {
+		t.Fatalf("FromObject returned an error: %s", err)
+	}
+	if native.(map[string]interface{})["age"] != int64(30) {
+		t.Errorf("wrong native value. got=%#v", native)
+	}
+
+	var counts map[string]int
+	if err := FromObject(mustRun(t, i, `{"a": 1, "b": 2}`), &counts); err != nil || counts["b"] != 2 {
+		t.Errorf("wrong map. got=%v, err=%v", counts, err)
+	}
+
+	var f float64
+	if err := FromObject(mustRun(t, i, `3`), &f); err != nil || f != 3 {
+		t.Errorf("wrong float. got=%v, err=%v", f, err)
+	}
+
+	errorTests := []struct {
+		input    string
+		target   interface{}
+		expected string
+	}{
+		{`"x"`, new(int), "cannot convert STRING to int"},
+		{`300`, new(int8), "300 overflows int8"},
+		{`-1`, new(uint), "-1 overflows uint"},
+		{`[1, "x"]`, new([]int), "element 1: cannot convert STRING to int"},
+		{`[1, 2]`, new([3]int), "cannot convert ARRAY of length 2 to [3]int"},
+		{`{"age": "old"}`, new(person), "field age: cannot convert STRING to int"},
+		{`{1: 2}`, new(interface{}), "cannot convert HASH with INTEGER key to map[string]interface {}"},
+	}
+
+	for _, tt := range errorTests {
+		err := FromObject(mustRun(t, i, tt.input), tt.target)
+		if err == nil || err.Error() != tt.expected {
+			t.Errorf("FromObject(%s) wrong error. want=%q, got=%v", tt.input, tt.expected, err)
+		}
+	}
+}
+
+func TestWrapFunc(t *testing.T) {
+	i := New()
+	err := i.Register("label", func(n int, name string) (string, error) {
+		if n < 0 {
+			return "", errors.New("n must not be negative")
+		}
+		return strings.Repeat(name, n), nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := i.Register("sum", func(nums ...int) int {
+		total := 0
+		for _, n := range nums {
+			total += n
+		}
+		return total
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := i.Set("describe", func(p person) string { return p.Name + " from " + p.Address.City }); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{`label(2, "ab")`, "abab"},
+		{`sum()`, "0"},
+		{`sum(1, 2, 3)`, "6"},
+		{`describe({"name": "Ann", "address": {"city": "Oslo"}})`, "Ann from Oslo"},
+		{`map([1, 2], fn(n) { label(n, "x") })`, "[x, xx]"},
+	}
+
+	for _, tt := range tests {
+		res, err := i.Run(tt.input)
+		if err != nil || res.Inspect() != tt.expected {
+			t.Errorf("%s: wrong result. want=%s, got=%v, err=%v", tt.input, tt.expected, res, err)
+		}
+	}
+
+	errorTests := []struct {
+		input    string
+		expected string
+	}{
+		{`label(1)`, "wrong number of arguments. got=1, want=2"},
+		{`label("1", "x")`, "argument 1: cannot convert STRING to int"},
+		{`label(-1, "x")`, "n must not be negative"},
+		{`sum(1, true)`, "argument 2: cannot convert BOOLEAN to int"},
+	}
+
+	for _, tt := range errorTests {
+		_, err := i.Run(tt.input)
+		var runtimeErr *RuntimeError
+		if !errors.As(err, &runtimeErr) || runtimeErr.Error() != tt.expected {
+			t.Errorf("%s: wrong error. want=%q, got=%v", tt.input, tt.expected, err)
+		}
+	}
+
+	if _, err := WrapFunc(42); err == nil {
+		t.Errorf("expected an error wrapping a non func")
+	}
+
+	if _, err := WrapFunc(func() (int, int) { return 1, 2 }); err == nil {
+		t.Errorf("expected an error wrapping a func with two results")
+	}
+}
+
+func TestWrapFuncObjects(t *testing.T) {
+	builtin, err := WrapFunc(func(obj object.Object, hash *object.Hash) object.Object {
+		value, _ := hash.Get(obj)
+		return value
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	i := New()
+	i.Set("lookup", builtin)
+	res, err := i.Run(`lookup("a", {"a": [1]})`)
+	if err != nil || res.Inspect() != "[1]" {
+		t.Errorf("wrong result. got=%v, err=%v", res, err)
+	}
+
+	if _, err := i.Run(`lookup("a", [1])`); err == nil || err.Error() != "argument 2: cannot convert ARRAY to *object.Hash" {
+		t.Errorf("wrong error. got=%v", err)
+	}
+}
+
+func mustRun(t *testing.T, i *Interpreter, source string) object.Object {
+	t.Helper()
+	obj, err := i.Run(source)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return obj
+}
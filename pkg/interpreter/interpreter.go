@@ -0,0 +1,316 @@
+package interpreter
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/AhmedThresh/not-even-a-compiler/pkg/eval"
+	"github.com/AhmedThresh/not-even-a-compiler/pkg/lexer"
+	"github.com/AhmedThresh/not-even-a-compiler/pkg/object"
+	"github.com/AhmedThresh/not-even-a-compiler/pkg/parser"
+)
+
+// Interpreter runs Monkey code on behalf of a Go host. Every Interpreter
+// owns its globals, builtins and output streams, so several of them can be
+// used side by side without affecting each other.
+type Interpreter struct {
+	env *object.Environment
+}
+
+type Option func(*Interpreter)
+
+// WithStdout sets the writer receiving the output of puts and print
+func WithStdout(w io.Writer) Option {
+	return func(i *Interpreter) {
+		i.env.Runtime().Stdout = w
+	}
+}
+
+// WithStderr sets the writer receiving the output of eprint
+func WithStderr(w io.Writer) Option {
+	return func(i *Interpreter) {
+		i.env.Runtime().Stderr = w
+	}
+}
+
+// WithStdin sets the reader read_line consumes
+func WithStdin(r io.Reader) Option {
+	return func(i *Interpreter) {
+		i.env.Runtime().Stdin = r
+	}
+}
+
+// WithFileRoot enables the file system builtins, confined to dir
+func WithFileRoot(dir string) Option {
+	return func(i *Interpreter) {
+		i.env.Runtime().FileRoot = dir
+	}
+}
+
+// WithBuiltin makes fn available to scripts under name, replacing any
+// default builtin with the same name
+func WithBuiltin(name string, fn object.BuiltinFunction) Option {
+	return func(i *Interpreter) {
+		i.env.Runtime().Builtins[name] = &object.Builtin{Fn: fn, Name: name}
+	}
+}
+
+// WithNondeterministicBuiltin is like WithBuiltin for builtins whose result
+// may vary between runs, such as those reading the clock or the network.
+// Scripts cannot call them in deterministic mode.
+func WithNondeterministicBuiltin(name string, fn object.BuiltinFunction) Option {
+	return func(i *Interpreter) {
+		i.env.Runtime().Builtins[name] = &object.Builtin{Fn: fn, Name: name, Nondeterministic: true}
+	}
+}
+
+// WithoutBuiltin hides a default builtin from scripts
+func WithoutBuiltin(name string) Option {
+	return func(i *Interpreter) {
+		delete(i.env.Runtime().Builtins, name)
+	}
+}
+
+// WithMaxSteps limits the number of nodes a single Run or Call may evaluate
+func WithMaxSteps(n int) Option {
+	return func(i *Interpreter) {
+		i.env.Runtime().MaxSteps = n
+	}
+}
+
+// WithMaxDepth limits how deeply function calls may nest, replacing
+// eval.DefaultMaxDepth
+func WithMaxDepth(n int) Option {
+	return func(i *Interpreter) {
+		i.env.Runtime().MaxDepth = n
+	}
+}
+
+// WithMaxMemory limits the approximate number of bytes a single Run or Call
+// may allocate
+func WithMaxMemory(bytes int64) Option {
+	return func(i *Interpreter) {
+		i.env.Runtime().MaxMemory = bytes
+	}
+}
+
+// WithGasLimit meters every Run or Call, stopping it with an OUT_OF_GAS
+// error once more than limit gas is used
+func WithGasLimit(limit int64) Option {
+	return func(i *Interpreter) {
+		i.env.Runtime().GasLimit = limit
+	}
+}
+
+// WithGasCosts replaces the default cost table, see eval.DefaultGasCosts
+func WithGasCosts(costs *object.GasCosts) Option {
+	return func(i *Interpreter) {
+		i.env.Runtime().GasCosts = costs
+	}
+}
+
+// WithDeterministic refuses calls to nondeterministic builtins such as puts
+// and the file system builtins
+func WithDeterministic() Option {
+	return func(i *Interpreter) {
+		i.env.Runtime().Deterministic = true
+	}
+}
+
+// WithModulePath adds directories searched for imported modules that are
+// not found next to the importing file
+func WithModulePath(dirs ...string) Option {
+	return func(i *Interpreter) {
+		i.env.Runtime().ModulePaths = append(i.env.Runtime().ModulePaths, dirs...)
+	}
+}
+
+func New(options ...Option) *Interpreter {
+	i := &Interpreter{env: object.NewEnvironment()}
+	i.env.Runtime().Builtins = eval.Builtins()
+
+	for _, option := range options {
+		option(i)
+	}
+	return i
+}
+
+// ParseError is returned when the source cannot be parsed
+type ParseError struct {
+	Errors []string
+}
+
+func (e *ParseError) Error() string {
+	return "parser errors: " + strings.Join(e.Errors, "; ")
+}
+
+// RuntimeError is returned when evaluation ends with an ERROR object
+type RuntimeError struct {
+	Err *object.Error
+}
+
+func (e *RuntimeError) Error() string {
+	return e.Err.Message
+}
+
+// Traceback formats the error with the calls it unwound through
+func (e *RuntimeError) Traceback() string {
+	return e.Err.Traceback()
+}
+
+// Run evaluates source in the interpreter's global environment and returns
+// the value of the last statement
+func (i *Interpreter) Run(source string) (object.Object, error) {
+	return i.RunContext(context.Background(), source)
+}
+
+// RunContext is like Run but stops the evaluation with a CANCELED error
+// once ctx is done
+func (i *Interpreter) RunContext(ctx context.Context, source string) (object.Object, error) {
+	p := parser.NewParser(lexer.NewLexer(source))
+	program := p.ParseProgram()
+	if len(p.Errors()) != 0 {
+		return nil, &ParseError{Errors: p.Errors()}
+	}
+
+	defer i.begin(ctx)()
+	return result(eval.Eval(program, i.env))
+}
+
+// RunFile reads the file at path and runs its content, modules it imports
+// are resolved relative to it
+func (i *Interpreter) RunFile(path string) (object.Object, error) {
+	path, err := filepath.Abs(path)
+	if err != nil {
+		return nil, err
+	}
+	if real, err := filepath.EvalSymlinks(path); err == nil {
+		path = real
+	}
+
+	source, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	i.env.SetPath(path)
+	return i.Run(string(source))
+}
+
+// Get returns the value of a global binding
+func (i *Interpreter) Get(name string) (object.Object, bool) {
+	return i.env.Get(name)
+}
+
+// Set binds a Go value to a global name, converting it with ToObject
+func (i *Interpreter) Set(name string, value interface{}) error {
+	obj, err := ToObject(value)
+	if err != nil {
+		return err
+	}
+
+	i.env.Store(name, obj)
+	return nil
+}
+
+// Register wraps a Go func with WrapFunc and makes it available to scripts
+// as a builtin under name
+func (i *Interpreter) Register(name string, fn interface{}) error {
+	builtin, err := WrapFunc(fn)
+	if err != nil {
+		return err
+	}
+
+	builtin.Name = name
+	i.env.Runtime().Builtins[name] = builtin
+	return nil
+}
+
+// RegisterNondeterministic is like Register for funcs whose result may vary
+// between runs, scripts cannot call them in deterministic mode
+func (i *Interpreter) RegisterNondeterministic(name string, fn interface{}) error {
+	if err := i.Register(name, fn); err != nil {
+		return err
+	}
+
+	i.env.Runtime().Builtins[name].Nondeterministic = true
+	return nil
+}
+
+// Call invokes the function bound to the global name, or the builtin with
+// that name, with Go values as arguments
+func (i *Interpreter) Call(name string, args ...interface{}) (object.Object, error) {
+	return i.CallContext(context.Background(), name, args...)
+}
+
+// CallContext is like Call but stops the evaluation with a CANCELED error
+// once ctx is done
+func (i *Interpreter) CallContext(ctx context.Context, name string, args ...interface{}) (object.Object, error) {
+	fn, ok := i.env.Get(name)
+	if !ok {
+		builtin, found := i.env.Runtime().Builtins[name]
+		if !found {
+			return nil, fmt.Errorf("identifier not found: %s", name)
+		}
+		fn = builtin
+	}
+
+	objects := make([]object.Object, len(args))
+	for idx, arg := range args {
+		obj, err := ToObject(arg)
+		if err != nil {
+			return nil, fmt.Errorf("argument %d: %w", idx, err)
+		}
+		objects[idx] = obj
+	}
+
+	defer i.begin(ctx)()
+	return result(eval.Apply(fn, objects, i.env))
+}
+
+// Steps returns the number of nodes evaluated by the last Run or Call
+func (i *Interpreter) Steps() int {
+	return i.env.Runtime().Steps
+}
+
+// MemoryUsed returns the approximate number of bytes allocated by the last
+// Run or Call
+func (i *Interpreter) MemoryUsed() int64 {
+	return i.env.Runtime().Memory
+}
+
+// GasUsed returns the gas used by the last Run or Call, it stays zero unless
+// a gas limit is set
+func (i *Interpreter) GasUsed() int64 {
+	return i.env.Runtime().GasUsed
+}
+
+// begin resets the per run accounting and installs ctx, the returned func
+// removes it again
+func (i *Interpreter) begin(ctx context.Context) func() {
+	runtime := i.env.Runtime()
+	runtime.Context = ctx
+	runtime.Steps = 0
+	runtime.Depth = 0
+	runtime.Memory = 0
+	runtime.GasUsed = 0
+
+	return func() {
+		runtime.Context = nil
+	}
+}
+
+func result(obj object.Object) (object.Object, error) {
+	if errObj, ok := obj.(*object.Error); ok {
+		return nil, &RuntimeError{Err: errObj}
+	}
+
+	if obj == nil {
+		return eval.NULL, nil
+	}
+	return obj, nil
+}
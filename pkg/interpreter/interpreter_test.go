@@ -0,0 +1,369 @@
+package interpreter
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/AhmedThresh/not-even-a-compiler/pkg/eval"
+	"github.com/AhmedThresh/not-even-a-compiler/pkg/object"
+)
+
+func TestRun(t *testing.T) {
+	var stdout bytes.Buffer
+	i := New(WithStdout(&stdout))
+
+	res, err := i.Run(`let add = fn(a, b) { a + b }; puts(add(1, 2)); add(2, 3)`)
+	if err != nil {
+		t.Fatalf("Run returned an error: %s", err)
+	}
+
+	if res.Inspect() != "5" {
+		t.Errorf("wrong result. got=%s", res.Inspect())
+	}
+	if stdout.String() != "3\n" {
+		t.Errorf("wrong stdout. got=%q", stdout.String())
+	}
+
+	// globals persist between runs
+	res, err = i.Run(`add(10, 10)`)
+	if err != nil || res.Inspect() != "20" {
+		t.Errorf("wrong result of second run. got=%v, err=%v", res, err)
+	}
+}
+
+func TestRunErrors(t *testing.T) {
+	i := New()
+
+	_, err := i.Run(`let = 5;`)
+	var parseErr *ParseError
+	if !errors.As(err, &parseErr) || len(parseErr.Errors) == 0 {
+		t.Errorf("expected ParseError. got=%T (%v)", err, err)
+	}
+
+	_, err = i.Run(`1 + true`)
+	var runtimeErr *RuntimeError
+	if !errors.As(err, &runtimeErr) {
+		t.Fatalf("expected RuntimeError. got=%T (%v)", err, err)
+	}
+	if runtimeErr.Error() != "type mismatch: INTEGER + BOOLEAN" {
+		t.Errorf("wrong error message. got=%q", runtimeErr.Error())
+	}
+
+	_, err = i.Run("let f = fn() {\n  1 + true\n};\nf()")
+	if !errors.As(err, &runtimeErr) {
+		t.Fatalf("expected RuntimeError. got=%T (%v)", err, err)
+	}
+	if runtimeErr.Traceback() != "ERROR: type mismatch: INTEGER + BOOLEAN\n  at f (line 4, column 2)" {
+		t.Errorf("wrong traceback. got=%q", runtimeErr.Traceback())
+	}
+}
+
+func TestRunFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "script.mk")
+	if err := os.WriteFile(path, []byte(`let x = 21; x * 2`), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	res, err := New().RunFile(path)
+	if err != nil || res.Inspect() != "42" {
+		t.Errorf("wrong result. got=%v, err=%v", res, err)
+	}
+}
+
+func TestModules(t *testing.T) {
+	dir, lib := t.TempDir(), t.TempDir()
+	files := map[string]string{
+		filepath.Join(dir, "main.mk"):    `let {double} = import "helpers"; let fmt = import "fmt"; fmt["label"](double(21))`,
+		filepath.Join(dir, "helpers.mk"): `export let double = fn(x) { x * 2 };`,
+		filepath.Join(lib, "fmt.mk"):     `export let label = fn(x) { "value: " + str(x) };`,
+	}
+	for path, source := range files {
+		if err := os.WriteFile(path, []byte(source), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	res, err := New(WithModulePath(lib)).RunFile(filepath.Join(dir, "main.mk"))
+	if err != nil || res.Inspect() != "value: 42" {
+		t.Errorf("wrong result. got=%v, err=%v", res, err)
+	}
+
+	_, err = New().RunFile(filepath.Join(dir, "main.mk"))
+	if err == nil || err.Error() != "module not found: fmt" {
+		t.Errorf("expected fmt to be missing without the search path. got=%v", err)
+	}
+}
+
+func TestGlobals(t *testing.T) {
+	i := New()
+	if err := i.Set("config", map[string]interface{}{"name": "monkey", "tags": []interface{}{"a", 1, true}}); err != nil {
+		t.Fatalf("Set returned an error: %s", err)
+	}
+
+	res, err := i.Run(`let greeting = config["name"] + "!"; config["tags"][1]`)
+	if err != nil || res.Inspect() != "1" {
+		t.Errorf("wrong result. got=%v, err=%v", res, err)
+	}
+
+	greeting, ok := i.Get("greeting")
+	if !ok || greeting.Inspect() != "monkey!" {
+		t.Errorf("wrong global. got=%v", greeting)
+	}
+
+	if err := i.Set("bad", make(chan int)); err == nil {
+		t.Errorf("expected an error for an unsupported value")
+	}
+}
+
+func TestCall(t *testing.T) {
+	i := New()
+	if _, err := i.Run(`let greet = fn(name, times) { repeat("hi " + name + " ", times) }`); err != nil {
+		t.Fatal(err)
+	}
+
+	res, err := i.Call("greet", "bob", 2)
+	if err != nil || res.Inspect() != "hi bob hi bob " {
+		t.Errorf("wrong result. got=%v, err=%v", res, err)
+	}
+
+	res, err = i.Call("len", []interface{}{1, 2, 3})
+	if err != nil || res.Inspect() != "3" {
+		t.Errorf("wrong result calling a builtin. got=%v, err=%v", res, err)
+	}
+
+	if _, err := i.Call("missing"); err == nil {
+		t.Errorf("expected an error calling an unknown function")
+	}
+
+	var runtimeErr *RuntimeError
+	if _, err := i.Call("greet", "bob", "x"); !errors.As(err, &runtimeErr) {
+		t.Errorf("expected RuntimeError. got=%T (%v)", err, err)
+	}
+}
+
+func TestBuiltinOptions(t *testing.T) {
+	double := func(ctx *object.CallContext, args ...object.Object) object.Object {
+		return &object.Integer{Value: args[0].(*object.Integer).Value * 2}
+	}
+	i := New(WithBuiltin("double", double), WithoutBuiltin("puts"))
+
+	res, err := i.Run(`double(21)`)
+	if err != nil || res.Inspect() != "42" {
+		t.Errorf("wrong result. got=%v, err=%v", res, err)
+	}
+
+	if _, err := i.Run(`puts(1)`); err == nil || err.Error() != "identifier not found: puts" {
+		t.Errorf("puts should be unavailable. got=%v", err)
+	}
+}
+
+func TestStdin(t *testing.T) {
+	i := New(WithStdin(strings.NewReader("first\nsecond")))
+
+	res, err := i.Run(`[read_line(), read_line(), read_line()]`)
+	if err != nil || res.Inspect() != "[first, second, NULL]" {
+		t.Errorf("wrong result. got=%v, err=%v", res, err)
+	}
+}
+
+func TestIsolation(t *testing.T) {
+	var out1, out2 bytes.Buffer
+	double := func(ctx *object.CallContext, args ...object.Object) object.Object {
+		return &object.Integer{Value: 2}
+	}
+	first := New(WithStdout(&out1), WithBuiltin("double", double))
+	second := New(WithStdout(&out2))
+
+	if _, err := first.Run(`let x = 1; puts("first")`); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := second.Run(`puts("second")`); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, ok := second.Get("x"); ok {
+		t.Errorf("globals leaked between interpreters")
+	}
+	if _, err := second.Run(`double(1)`); err == nil {
+		t.Errorf("builtins leaked between interpreters")
+	}
+	if out1.String() != "first\n" || out2.String() != "second\n" {
+		t.Errorf("output leaked between interpreters. got=%q and %q", out1.String(), out2.String())
+	}
+}
+
+func TestExecutionLimits(t *testing.T) {
+	i := New(WithMaxSteps(500), WithMaxDepth(20))
+
+	if _, err := i.Run(`let count = fn(n) { n == 0 ? 0 : 1 + count(n - 1) }; count(10)`); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := i.Run(`let squares = fn(arr) { map(arr, fn(x) { x * x }) }`); err != nil {
+		t.Fatal(err)
+	}
+	if i.Steps() == 0 {
+		t.Errorf("expected the steps of the run to be reported")
+	}
+
+	tests := []struct {
+		run  func() (object.Object, error)
+		kind object.ErrorKind
+	}{
+		{func() (object.Object, error) { return i.Run(`count(100)`) }, object.DEPTH_LIMIT_ERROR},
+		{func() (object.Object, error) { return i.Call("squares", make([]int, 200)) }, object.STEP_LIMIT_ERROR},
+		{func() (object.Object, error) {
+			ctx, cancel := context.WithTimeout(context.Background(), time.Nanosecond)
+			defer cancel()
+			<-ctx.Done()
+			return i.RunContext(ctx, `count(1)`)
+		}, object.CANCELED_ERROR},
+	}
+
+	for _, tt := range tests {
+		_, err := tt.run()
+		var runtimeErr *RuntimeError
+		if !errors.As(err, &runtimeErr) || runtimeErr.Err.Kind != tt.kind {
+			t.Errorf("expected a %s error. got=%v", tt.kind, err)
+		}
+	}
+
+	res, err := i.Run(`count(5)`)
+	if err != nil || res.Inspect() != "5" {
+		t.Errorf("limits should reset between runs. got=%v, err=%v", res, err)
+	}
+}
+
+func TestCyclicValues(t *testing.T) {
+	const cycle = `let a = [1]; push(a, a); let h = {"a": a};`
+	tests := []struct {
+		input    string
+		expected string
+		err      string
+	}{
+		{`a == [1, a]`, "true", ""},
+		{`h == {"a": a}`, "true", ""},
+		{`{a: 1}`, "", "unusable as hash key: ARRAY"},
+		{`has(h, a)`, "", "unusable as hash key: ARRAY"},
+		{`json_stringify(h)`, "", "cannot convert cyclic structure to JSON"},
+		{`inspect(h)`, `{"a": [1, [...]]}`, ""},
+		{`str(a)`, "[1, [...]]", ""},
+		{`puts(a)`, "NULL", ""},
+	}
+
+	for _, tt := range tests {
+		var out bytes.Buffer
+		i := New(WithStdout(&out), WithMaxSteps(10000), WithMaxDepth(50), WithMaxMemory(1<<20), WithGasLimit(100000))
+
+		res, err := i.Run(cycle + tt.input)
+		if tt.err != "" {
+			var runtimeErr *RuntimeError
+			if !errors.As(err, &runtimeErr) || runtimeErr.Error() != tt.err {
+				t.Errorf("%s: expected error %q. got=%v", tt.input, tt.err, err)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%s: unexpected error %v", tt.input, err)
+			continue
+		}
+		if res.Inspect() != tt.expected {
+			t.Errorf("%s: wrong result. want=%s, got=%s", tt.input, tt.expected, res.Inspect())
+		}
+	}
+
+	res, err := New().Run(cycle + "h")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var native interface{}
+	if err := FromObject(res, &native); err == nil || err.Error() != "cannot convert cyclic ARRAY" {
+		t.Errorf("expected FromObject to refuse the cyclic hash. got=%v", err)
+	}
+}
+
+func TestMemoryLimit(t *testing.T) {
+	i := New(WithMaxMemory(4096))
+
+	if _, err := i.Run(`let xs = [1, 2, 3]; push(xs, 4)`); err != nil {
+		t.Fatal(err)
+	}
+	if i.MemoryUsed() == 0 {
+		t.Errorf("expected the memory used by the run to be reported")
+	}
+
+	_, err := i.Run(`let grow = fn(arr) { grow(push(arr, len(arr))) }; grow([])`)
+	var runtimeErr *RuntimeError
+	if !errors.As(err, &runtimeErr) || runtimeErr.Err.Kind != object.MEMORY_LIMIT_ERROR {
+		t.Errorf("expected a MEMORY_LIMIT error. got=%v", err)
+	}
+	if i.MemoryUsed() <= 4096 {
+		t.Errorf("expected the final usage to exceed the budget. got=%d", i.MemoryUsed())
+	}
+}
+
+func TestGasMetering(t *testing.T) {
+	run := func() (int64, error) {
+		i := New(WithGasLimit(200), WithDeterministic())
+		_, err := i.Run(`let sum = fn(xs) { reduce(xs, fn(acc, x) { acc + x }, 0) }; sum([1, 2, 3])`)
+		return i.GasUsed(), err
+	}
+
+	first, err := run()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if second, _ := run(); first == 0 || first != second {
+		t.Errorf("gas used should be reported and stable. got=%d and %d", first, second)
+	}
+
+	i := New(WithGasLimit(first - 1))
+	_, err = i.Run(`let sum = fn(xs) { reduce(xs, fn(acc, x) { acc + x }, 0) }; sum([1, 2, 3])`)
+	var runtimeErr *RuntimeError
+	if !errors.As(err, &runtimeErr) || runtimeErr.Err.Kind != object.OUT_OF_GAS_ERROR {
+		t.Errorf("expected an OUT_OF_GAS error. got=%v", err)
+	}
+
+	costs := eval.DefaultGasCosts()
+	costs.Builtins["double"] = 1000
+	i = New(WithGasLimit(500), WithGasCosts(costs), WithBuiltin("double", func(ctx *object.CallContext, args ...object.Object) object.Object {
+		return args[0]
+	}))
+	if _, err := i.Run(`double(1)`); err == nil {
+		t.Errorf("expected the custom cost to be charged")
+	}
+
+	if _, err := New(WithDeterministic()).Run(`puts(1)`); err == nil {
+		t.Errorf("expected puts to be refused in deterministic mode")
+	}
+}
+
+func TestNondeterministicBuiltins(t *testing.T) {
+	now := func(ctx *object.CallContext, args ...object.Object) object.Object {
+		return &object.Integer{Value: time.Now().Unix()}
+	}
+
+	i := New(WithNondeterministicBuiltin("now", now))
+	if err := i.RegisterNondeterministic("random", func() int64 { return 4 }); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := i.Run(`now() + random()`); err != nil {
+		t.Errorf("nondeterministic builtins should run by default. got=%v", err)
+	}
+
+	i = New(WithDeterministic(), WithNondeterministicBuiltin("now", now))
+	if err := i.RegisterNondeterministic("random", func() int64 { return 4 }); err != nil {
+		t.Fatal(err)
+	}
+	for _, name := range []string{"now", "random"} {
+		_, err := i.Run(name + "()")
+		if err == nil || err.Error() != "`"+name+"` is not available in deterministic mode" {
+			t.Errorf("expected %s to be refused in deterministic mode. got=%v", name, err)
+		}
+	}
+}
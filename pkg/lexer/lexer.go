@@ -1,6 +1,10 @@
 package lexer
 
 import (
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
 	"github.com/AhmedThresh/not-even-a-compiler/pkg/token"
 )
 
@@ -8,31 +12,49 @@ type Lexer struct {
 	code            string
 	currentPosition int  // current position in input
 	readPosition    int  // current reading position
-	currentCh       byte // current char under examination
+	currentCh       rune // current char under examination
+	line            int  // line of currentCh
+	column          int  // column of currentCh, counted in characters
 }
 
 func NewLexer(code string) *Lexer {
 	l := &Lexer{
 		code: code,
+		line: 1,
 	}
 	l.readCh()
 	return l
 }
 
 func (l *Lexer) readCh() {
+	if l.currentCh == '\n' {
+		l.line++
+		l.column = 0
+	}
+	l.column++
+
+	width := 1
 	if l.readPosition >= len(l.code) {
 		l.currentCh = 0
 	} else {
-		l.currentCh = l.code[l.readPosition]
+		l.currentCh, width = utf8.DecodeRuneInString(l.code[l.readPosition:])
 	}
 
 	l.currentPosition = l.readPosition
-	l.readPosition += 1
+	l.readPosition += width
 }
 
 func (l *Lexer) NextToken() token.Token {
-	var t token.Token
 	l.skipWhitespace()
+	line, column := l.line, l.column
+
+	t := l.readToken()
+	t.Line, t.Column = line, column
+	return t
+}
+
+func (l *Lexer) readToken() token.Token {
+	var t token.Token
 	switch l.currentCh {
 	case '=':
 		if l.peekChar() == '=' {
@@ -42,6 +64,13 @@ func (l *Lexer) NextToken() token.Token {
 				Literal: string(ch) + string(l.currentCh),
 				Type:    token.EQ,
 			}
+		} else if l.peekChar() == '>' {
+			ch := l.currentCh
+			l.readCh()
+			t = token.Token{
+				Literal: string(ch) + string(l.currentCh),
+				Type:    token.ARROW,
+			}
 		} else {
 			t = token.NewToken(token.ASSIGN, l.currentCh)
 		}
@@ -72,6 +101,16 @@ func (l *Lexer) NextToken() token.Token {
 		t = token.NewToken(token.SEMICOLON, l.currentCh)
 	case ':':
 		t = token.NewToken(token.COLON, l.currentCh)
+	case '?':
+		t = token.NewToken(token.QUESTION, l.currentCh)
+	case '.':
+		if strings.HasPrefix(l.code[l.currentPosition:], "...") {
+			l.readCh()
+			l.readCh()
+			t = token.Token{Type: token.ELLIPSIS, Literal: "..."}
+		} else {
+			t = token.NewToken(token.ILLEGAL, l.currentCh)
+		}
 	case ',':
 		t = token.NewToken(token.COMMA, l.currentCh)
 	case '(':
@@ -115,11 +154,12 @@ func (l *Lexer) skipWhitespace() {
 	}
 }
 
-func (l *Lexer) peekChar() byte {
+func (l *Lexer) peekChar() rune {
 	if l.readPosition >= len(l.code) {
 		return 0
 	}
-	return l.code[l.readPosition]
+	ch, _ := utf8.DecodeRuneInString(l.code[l.readPosition:])
+	return ch
 }
 
 func (l *Lexer) readIdentifier() string {
@@ -158,10 +198,10 @@ func (l *Lexer) readString() string {
 	// return l.code[position:l.currentPosition]
 }
 
-func isLetter(ch byte) bool {
-	return 'a' <= ch && ch <= 'z' || 'A' <= ch && ch <= 'Z' || ch == '_'
+func isLetter(ch rune) bool {
+	return unicode.IsLetter(ch) || ch == '_'
 }
 
-func isDigit(ch byte) bool {
+func isDigit(ch rune) bool {
 	return ch >= '0' && ch <= '9'
 }
@@ -1,22 +1,146 @@
 package object
 
+import (
+	"context"
+	"io"
+	"os"
+)
+
 type Environment struct {
-	store map[string]Object
-	outer *Environment
+	store   map[string]Object
+	outer   *Environment
+	runtime *Runtime
+
+	// path is the source file evaluated in a top-level environment and
+	// exports the names of the bindings it exports
+	path    string
+	exports []string
+}
+
+// Runtime holds the host configuration shared by an environment and every
+// environment enclosed by it
+type Runtime struct {
+	// FileRoot is the directory the file system builtins are confined to.
+	// The file system builtins are disabled while it is empty.
+	FileRoot string
+
+	// Stdin is read by `read_line`, Stdout and Stderr receive everything
+	// scripts print
+	Stdin  io.Reader
+	Stdout io.Writer
+	Stderr io.Writer
+
+	// Builtins replaces the default set of builtin functions when not nil
+	Builtins map[string]*Builtin
+
+	// Context stops the evaluation once it is done when not nil
+	Context context.Context
+
+	// MaxSteps is the number of nodes that may be evaluated, zero means
+	// no limit. Steps counts the nodes evaluated so far.
+	MaxSteps int
+	Steps    int
+
+	// MaxDepth is the number of nested function calls allowed, zero means
+	// the evaluator's default. Depth is the current nesting.
+	MaxDepth int
+	Depth    int
+
+	// MaxMemory is the approximate number of bytes scripts may allocate,
+	// zero means no limit. Memory is the number allocated so far.
+	MaxMemory int64
+	Memory    int64
+
+	// GasLimit turns on gas metering when positive, evaluation stops once
+	// GasUsed exceeds it. GasCosts prices the operations, nil means the
+	// evaluator's default table.
+	GasLimit int64
+	GasUsed  int64
+	GasCosts *GasCosts
+
+	// Deterministic refuses calls to nondeterministic builtins
+	Deterministic bool
+
+	// ModulePaths are the directories searched for imported modules not
+	// found next to the importing file
+	ModulePaths []string
+
+	// Modules caches the modules imported so far by their absolute path.
+	// Importing is the chain of modules currently being evaluated.
+	Modules   map[string]*Module
+	Importing []string
+}
+
+// GasCosts prices evaluation for gas metering. Nodes is keyed by AST node
+// kind, such as "CallExpression", and Builtins by builtin name. Whatever is
+// missing costs DefaultNode or DefaultBuiltin. PerUnit is keyed by builtin
+// name as well and charged on top for every element, hash pair or byte of
+// the builtin's arguments and result.
+type GasCosts struct {
+	Nodes          map[string]int64
+	Builtins       map[string]int64
+	PerUnit        map[string]int64
+	DefaultNode    int64
+	DefaultBuiltin int64
 }
 
 func NewEnclosedEnvironment(outer *Environment) *Environment {
 	return &Environment{
-		store: make(map[string]Object),
-		outer: outer,
+		store:   make(map[string]Object),
+		outer:   outer,
+		runtime: outer.runtime,
 	}
 
 }
 
+// NewModuleEnvironment returns the top-level environment of the module at
+// path, sharing the runtime of importer
+func NewModuleEnvironment(importer *Environment, path string) *Environment {
+	return &Environment{
+		store:   make(map[string]Object),
+		runtime: importer.runtime,
+		path:    path,
+	}
+}
+
 func NewEnvironment() *Environment {
 	return &Environment{
-		store: make(map[string]Object),
+		store:   make(map[string]Object),
+		runtime: &Runtime{Stdin: os.Stdin, Stdout: os.Stdout, Stderr: os.Stderr},
+	}
+}
+
+func (e *Environment) Runtime() *Runtime {
+	return e.runtime
+}
+
+// Path returns the source file the environment belongs to, empty for code
+// that does not come from a file
+func (e *Environment) Path() string {
+	if e.path == "" && e.outer != nil {
+		return e.outer.Path()
 	}
+	return e.path
+}
+
+func (e *Environment) SetPath(path string) {
+	e.path = path
+}
+
+// TopLevel reports whether e is the outermost environment of its module
+func (e *Environment) TopLevel() bool {
+	return e.outer == nil
+}
+
+// Export marks the binding name as exported
+func (e *Environment) Export(name string) {
+	e.exports = append(e.exports, name)
+}
+
+// Exports returns the names of the exported bindings in the order they were
+// exported, a name exported twice is listed twice
+func (e *Environment) Exports() []string {
+	return e.exports
 }
 
 func (e *Environment) Store(identifier string, value Object) {
@@ -26,7 +150,7 @@ func (e *Environment) Store(identifier string, value Object) {
 func (e *Environment) Get(identifier string) (Object, bool) {
 	obj, ok := e.store[identifier]
 	if e.outer != nil && !ok {
-		obj, ok = e.outer.store[identifier]
+		obj, ok = e.outer.Get(identifier)
 	}
 	return obj, ok
 }
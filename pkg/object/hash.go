@@ -0,0 +1,191 @@
+package object
+
+import (
+	"encoding/binary"
+	"hash"
+	"hash/fnv"
+)
+
+type Hashable interface {
+	HashKey() HashKey
+}
+
+// HashKey only narrows down the candidates for a key: different keys can
+// share a HashKey, so lookups always confirm a match with Equals
+type HashKey struct {
+	Type  ObjectType
+	Value int64
+}
+
+// IsHashable reports whether obj can be used as a hash key. Arrays and
+// hashes are hashable when everything they contain is hashable, those that
+// contain themselves are not.
+func IsHashable(obj Object) bool {
+	return isHashable(obj, map[Object]bool{})
+}
+
+func isHashable(obj Object, checking map[Object]bool) bool {
+	switch obj := obj.(type) {
+	case *Integer, *String, *Boolean:
+		return true
+	case *Array:
+		if checking[obj] {
+			return false
+		}
+		checking[obj] = true
+		defer delete(checking, obj)
+
+		for _, el := range obj.Elements {
+			if !isHashable(el, checking) {
+				return false
+			}
+		}
+		return true
+	case *Hash:
+		if checking[obj] {
+			return false
+		}
+		checking[obj] = true
+		defer delete(checking, obj)
+
+		for _, pair := range obj.Pairs() {
+			if !isHashable(pair.Value, checking) {
+				return false
+			}
+		}
+		return true
+	default:
+		return false
+	}
+}
+
+func writeHashKey(h hash.Hash64, key HashKey) {
+	h.Write([]byte(key.Type))
+	binary.Write(h, binary.LittleEndian, key.Value)
+}
+
+// freezeKey deep copies arrays and hashes used as keys so that mutating the
+// original value afterwards cannot change a key stored in a hash
+func freezeKey(key Object) Object {
+	switch key := key.(type) {
+	case *Array:
+		elements := make([]Object, len(key.Elements))
+		for i, el := range key.Elements {
+			elements[i] = freezeKey(el)
+		}
+		return &Array{Elements: elements}
+	case *Hash:
+		frozen := NewHash()
+		for _, pair := range key.Pairs() {
+			frozen.Set(pair.Key, freezeKey(pair.Value))
+		}
+		return frozen
+	default:
+		return key
+	}
+}
+
+// CopyKey returns a copy of a key stored in a hash, so that scripts can be
+// handed the key without being able to change the stored one
+func CopyKey(key Object) Object {
+	return freezeKey(key)
+}
+
+type HashPair struct {
+	Key   Object
+	Value Object
+}
+
+// Hash keeps its pairs in insertion order; buckets maps each HashKey to the
+// positions in pairs of the keys sharing it
+type Hash struct {
+	pairs   []HashPair
+	buckets map[HashKey][]int
+
+	// caught is the error the hash describes to a catch block
+	caught *Error
+}
+
+func NewHash() *Hash {
+	return &Hash{buckets: make(map[HashKey][]int)}
+}
+
+// Get returns the value stored under key. Keys that are not hashable are
+// never found.
+func (h *Hash) Get(key Object) (Object, bool) {
+	if i, ok := h.find(key); ok {
+		return h.pairs[i].Value, true
+	}
+	return nil, false
+}
+
+// Set stores value under key. Replacing the value of an existing key keeps
+// its original position. The key must satisfy IsHashable.
+func (h *Hash) Set(key Object, value Object) {
+	if i, ok := h.find(key); ok {
+		h.pairs[i].Value = value
+		return
+	}
+
+	hashKey := key.(Hashable).HashKey()
+	h.buckets[hashKey] = append(h.buckets[hashKey], len(h.pairs))
+	h.pairs = append(h.pairs, HashPair{Key: freezeKey(key), Value: value})
+}
+
+func (h *Hash) find(key Object) (int, bool) {
+	if !IsHashable(key) {
+		return 0, false
+	}
+
+	for _, i := range h.buckets[key.(Hashable).HashKey()] {
+		if h.pairs[i].Key.Equals(key) {
+			return i, true
+		}
+	}
+	return 0, false
+}
+
+// SetCaught records that h describes err to a catch block, so that throwing
+// h again can rethrow err itself
+func (h *Hash) SetCaught(err *Error) {
+	h.caught = err
+}
+
+// Caught returns the error h was made to describe to a catch block, nil for
+// any other hash, including copies of it
+func (h *Hash) Caught() *Error {
+	return h.caught
+}
+
+func (h *Hash) Len() int {
+	return len(h.pairs)
+}
+
+// Pairs returns every key/value pair of the hash in insertion order
+func (h *Hash) Pairs() []HashPair {
+	pairs := make([]HashPair, len(h.pairs))
+	copy(pairs, h.pairs)
+	return pairs
+}
+
+// HashKey mixes the hash keys of every pair in an order independent way so
+// that equal hashes share a HashKey. It must only be called on hashes for
+// which IsHashable holds.
+func (h *Hash) HashKey() HashKey {
+	var sum uint64
+	for _, pair := range h.Pairs() {
+		pairHash := fnv.New64a()
+		writeHashKey(pairHash, pair.Key.(Hashable).HashKey())
+		writeHashKey(pairHash, pair.Value.(Hashable).HashKey())
+		sum += pairHash.Sum64()
+	}
+	return HashKey{Type: HASH, Value: int64(sum)}
+}
+
+func (h *Hash) Type() ObjectType { return HASH }
+func (h *Hash) Equals(other Object) bool {
+	return equals(h, other, map[comparison]bool{})
+}
+func (h *Hash) Inspect() string {
+	return inspect(h, map[Object]bool{})
+}
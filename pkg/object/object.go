@@ -4,6 +4,7 @@ import (
 	"bytes"
 	"fmt"
 	"hash/fnv"
+	"strconv"
 	"strings"
 
 	"github.com/AhmedThresh/not-even-a-compiler/pkg/ast"
@@ -11,6 +12,7 @@ import (
 
 const (
 	INTEGER          = "INTEGER"
+	FLOAT            = "FLOAT"
 	STRING           = "STRING"
 	BOOLEAN          = "BOOLEAN"
 	ARRAY            = "ARRAY"
@@ -20,6 +22,7 @@ const (
 	ERROR_OBJ        = "ERROR"
 	NULL             = "NULL"
 	BUILTIN          = "BUILTIN"
+	MODULE           = "MODULE"
 )
 
 type ObjectType string
@@ -27,15 +30,8 @@ type ObjectType string
 type Object interface {
 	Type() ObjectType
 	Inspect() string
-}
-
-type Hashable interface {
-	HashKey() HashKey
-}
-
-type HashKey struct {
-	Type  ObjectType
-	Value int64
+	// Equals reports whether the object is structurally equal to other
+	Equals(other Object) bool
 }
 
 type Integer struct {
@@ -50,10 +46,32 @@ func (i *Integer) Type() ObjectType {
 	return INTEGER
 }
 
+func (i *Integer) Equals(other Object) bool {
+	o, ok := other.(*Integer)
+	return ok && o.Value == i.Value
+}
+
 func (i *Integer) HashKey() HashKey {
 	return HashKey{Type: INTEGER, Value: i.Value}
 }
 
+type Float struct {
+	Value float64
+}
+
+func (f *Float) Inspect() string {
+	return strconv.FormatFloat(f.Value, 'g', -1, 64)
+}
+
+func (f *Float) Type() ObjectType {
+	return FLOAT
+}
+
+func (f *Float) Equals(other Object) bool {
+	o, ok := other.(*Float)
+	return ok && o.Value == f.Value
+}
+
 type String struct {
 	Value string
 }
@@ -66,6 +84,11 @@ func (s *String) Type() ObjectType {
 	return STRING
 }
 
+func (s *String) Equals(other Object) bool {
+	o, ok := other.(*String)
+	return ok && o.Value == s.Value
+}
+
 func (s *String) HashKey() HashKey {
 	h := fnv.New64a()
 	h.Write([]byte(s.Value))
@@ -84,6 +107,11 @@ func (b *Boolean) Type() ObjectType {
 	return BOOLEAN
 }
 
+func (b *Boolean) Equals(other Object) bool {
+	o, ok := other.(*Boolean)
+	return ok && o.Value == b.Value
+}
+
 func (b *Boolean) HashKey() HashKey {
 	var hash int64
 	if b.Value == true {
@@ -91,7 +119,7 @@ func (b *Boolean) HashKey() HashKey {
 	} else {
 		hash = 0
 	}
-	return HashKey{Type: STRING, Value: hash}
+	return HashKey{Type: BOOLEAN, Value: hash}
 }
 
 type Array struct {
@@ -99,16 +127,49 @@ type Array struct {
 }
 
 func (a *Array) Inspect() string {
+	return inspect(a, map[Object]bool{})
+}
+
+// inspect formats arrays and hashes element by element, printing holds the
+// containers being formatted so that one holding itself is shown as [...]
+// or {...} instead of being formatted forever
+func inspect(obj Object, printing map[Object]bool) string {
 	var out bytes.Buffer
-	elements := []string{}
 
-	for _, e := range a.Elements {
-		elements = append(elements, e.Inspect())
+	switch obj := obj.(type) {
+	case *Array:
+		if printing[obj] {
+			return "[...]"
+		}
+		printing[obj] = true
+		defer delete(printing, obj)
+
+		elements := []string{}
+		for _, e := range obj.Elements {
+			elements = append(elements, inspect(e, printing))
+		}
+
+		out.WriteString("[")
+		out.WriteString(strings.Join(elements, ", "))
+		out.WriteString("]")
+	case *Hash:
+		if printing[obj] {
+			return "{...}"
+		}
+		printing[obj] = true
+		defer delete(printing, obj)
+
+		pairs := []string{}
+		for _, pair := range obj.Pairs() {
+			pairs = append(pairs, fmt.Sprintf("%s: %s", pair.Key.Inspect(), inspect(pair.Value, printing)))
+		}
+
+		out.WriteString("{")
+		out.WriteString(strings.Join(pairs, ", "))
+		out.WriteString("}")
+	default:
+		return obj.Inspect()
 	}
-
-	out.WriteString("[")
-	out.WriteString(strings.Join(elements, ", "))
-	out.WriteString("]")
 	return out.String()
 }
 
@@ -116,26 +177,78 @@ func (a *Array) Type() ObjectType {
 	return ARRAY
 }
 
-type HashPair struct {
-	Key   Object
-	Value Object
-}
-
-type Hash struct {
-	Pairs map[HashKey]HashPair
-}
-
-func (h *Hash) Type() ObjectType { return HASH }
-func (h *Hash) Inspect() string {
-	var out bytes.Buffer
-	pairs := []string{}
-	for _, pair := range h.Pairs {
-		pairs = append(pairs, fmt.Sprintf("%s: %s", pair.Key.Inspect(), pair.Value.Inspect()))
+// HashKey combines the hash keys of the elements, so it must only be called
+// on arrays for which IsHashable holds
+func (a *Array) HashKey() HashKey {
+	h := fnv.New64a()
+	for _, el := range a.Elements {
+		writeHashKey(h, el.(Hashable).HashKey())
+	}
+	return HashKey{Type: ARRAY, Value: int64(h.Sum64())}
+}
+
+func (a *Array) Equals(other Object) bool {
+	return equals(a, other, map[comparison]bool{})
+}
+
+// comparison is a pair of containers being compared
+type comparison struct {
+	left, right Object
+}
+
+// equals compares arrays and hashes element by element. Containers can hold
+// themselves, so a comparison met again while it is still in progress is
+// reported as unequal instead of recursing forever.
+func equals(left, right Object, comparing map[comparison]bool) bool {
+	switch l := left.(type) {
+	case *Array:
+		r, ok := right.(*Array)
+		if !ok || len(r.Elements) != len(l.Elements) {
+			return false
+		}
+		if l == r {
+			return true
+		}
+
+		c := comparison{l, r}
+		if comparing[c] {
+			return false
+		}
+		comparing[c] = true
+		defer delete(comparing, c)
+
+		for i, el := range l.Elements {
+			if !equals(el, r.Elements[i], comparing) {
+				return false
+			}
+		}
+		return true
+	case *Hash:
+		r, ok := right.(*Hash)
+		if !ok || r.Len() != l.Len() {
+			return false
+		}
+		if l == r {
+			return true
+		}
+
+		c := comparison{l, r}
+		if comparing[c] {
+			return false
+		}
+		comparing[c] = true
+		defer delete(comparing, c)
+
+		for _, pair := range l.Pairs() {
+			value, ok := r.Get(pair.Key)
+			if !ok || !equals(pair.Value, value, comparing) {
+				return false
+			}
+		}
+		return true
+	default:
+		return left.Equals(right)
 	}
-	out.WriteString("{")
-	out.WriteString(strings.Join(pairs, ", "))
-	out.WriteString("}")
-	return out.String()
 }
 
 type ReturnValue struct {
@@ -150,20 +263,101 @@ func (r *ReturnValue) Type() ObjectType {
 	return RETURN_VALUE_OBJ
 }
 
+func (r *ReturnValue) Equals(other Object) bool {
+	o, ok := other.(*ReturnValue)
+	return ok && r.Value.Equals(o.Value)
+}
+
+// ErrorKind tells apart errors a host may want to handle differently, it is
+// empty for ordinary runtime errors
+type ErrorKind string
+
+const (
+	CANCELED_ERROR     ErrorKind = "CANCELED"
+	STEP_LIMIT_ERROR   ErrorKind = "STEP_LIMIT"
+	DEPTH_LIMIT_ERROR  ErrorKind = "DEPTH_LIMIT"
+	MEMORY_LIMIT_ERROR ErrorKind = "MEMORY_LIMIT"
+	OUT_OF_GAS_ERROR   ErrorKind = "OUT_OF_GAS"
+
+	// THROWN_ERROR is raised by throw statements
+	THROWN_ERROR ErrorKind = "THROWN"
+)
+
+// StackFrame is a function call an error unwound through
+type StackFrame struct {
+	Function string
+
+	// Line and Column locate the call site, they are zero for calls made
+	// by builtins or the host
+	Line   int
+	Column int
+}
+
+func (f StackFrame) String() string {
+	if f.Line == 0 {
+		return f.Function
+	}
+	return fmt.Sprintf("%s (line %d, column %d)", f.Function, f.Line, f.Column)
+}
+
 type Error struct {
 	Message string
+	Kind    ErrorKind
+
+	// Value is the value passed to throw for THROWN errors
+	Value Object
+
+	// Stack lists the calls the error unwound through, innermost first
+	Stack []StackFrame
+}
+
+// Catchable reports whether scripts may handle the error with try/catch.
+// Errors enforcing the host's limits always end the evaluation.
+func (e *Error) Catchable() bool {
+	return e.Kind == "" || e.Kind == THROWN_ERROR
 }
 
 func (e *Error) Inspect() string {
 	return "ERROR: " + e.Message
 }
 
+// Traceback formats the error followed by the calls it unwound through,
+// innermost first. Runs of the same frame, as left by deep recursion, are
+// collapsed into a single line.
+func (e *Error) Traceback() string {
+	var out bytes.Buffer
+	out.WriteString(e.Inspect())
+
+	for i := 0; i < len(e.Stack); {
+		frame := e.Stack[i]
+		repeated := 0
+		for i+repeated+1 < len(e.Stack) && e.Stack[i+repeated+1] == frame {
+			repeated++
+		}
+
+		out.WriteString("\n  at " + frame.String())
+		if repeated > 0 {
+			fmt.Fprintf(&out, "\n  ... repeated %d more times", repeated)
+		}
+		i += repeated + 1
+	}
+	return out.String()
+}
+
 func (e *Error) Type() ObjectType {
 	return ERROR_OBJ
 }
 
+func (e *Error) Equals(other Object) bool {
+	o, ok := other.(*Error)
+	return ok && o.Message == e.Message && o.Kind == e.Kind
+}
+
 type Function struct {
-	Parameters []*ast.Identifier
+	// Name is the name the function was first bound to with let, empty
+	// for anonymous functions
+	Name       string
+	Parameters []*ast.Parameter
 	Body       *ast.BlockStatement
 	Env        *Environment
 }
@@ -177,6 +371,9 @@ func (f *Function) Inspect() string {
 	}
 
 	out.WriteString("fn")
+	if f.Name != "" {
+		out.WriteString(" " + f.Name)
+	}
 	out.WriteString("(")
 	out.WriteString(strings.Join(params, ", "))
 	out.WriteString(") {\n")
@@ -190,10 +387,33 @@ func (f *Function) Type() ObjectType {
 	return FUNCTION
 }
 
-type BuiltinFunction func(...Object) Object
+// Equals compares functions by identity since closures over different
+// environments can behave differently even with identical bodies
+func (f *Function) Equals(other Object) bool {
+	return f == other
+}
+
+// CallContext is handed to builtins so they can reach back into the
+// evaluator running them
+type CallContext struct {
+	// Runtime is the runtime of the environment the builtin was called from
+	Runtime *Runtime
+	// Apply calls a FUNCTION or BUILTIN object with the given arguments
+	Apply func(fn Object, args ...Object) Object
+}
+
+type BuiltinFunction func(ctx *CallContext, args ...Object) Object
 
 type Builtin struct {
 	Fn BuiltinFunction
+
+	// Name is the name the builtin is registered under, it prices calls
+	// when metering gas
+	Name string
+
+	// Nondeterministic builtins, such as those doing I/O, are refused in
+	// deterministic mode
+	Nondeterministic bool
 }
 
 func (b *Builtin) Inspect() string {
@@ -204,6 +424,30 @@ func (b *Builtin) Type() ObjectType {
 	return BUILTIN
 }
 
+func (b *Builtin) Equals(other Object) bool {
+	return b == other
+}
+
+// Module is an imported source file, Exports maps the names of the bindings
+// it exports to their values. Path is relative to the directory of the entry
+// file or to the search path directory the module was found in.
+type Module struct {
+	Path    string
+	Exports *Hash
+}
+
+func (m *Module) Inspect() string {
+	return "module " + m.Path
+}
+
+func (m *Module) Type() ObjectType {
+	return MODULE
+}
+
+func (m *Module) Equals(other Object) bool {
+	return m == other
+}
+
 type Null struct{}
 
 func (n *Null) Inspect() string {
@@ -213,3 +457,8 @@ func (n *Null) Inspect() string {
 func (n *Null) Type() ObjectType {
 	return NULL
 }
+
+func (n *Null) Equals(other Object) bool {
+	_, ok := other.(*Null)
+	return ok
+}
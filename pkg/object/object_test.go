@@ -0,0 +1,224 @@
+package object
+
+import "testing"
+
+func TestEquals(t *testing.T) {
+	fn := &Function{}
+	tests := []struct {
+		left     Object
+		right    Object
+		expected bool
+	}{
+		{&Integer{Value: 1}, &Integer{Value: 1}, true},
+		{&Integer{Value: 1}, &Integer{Value: 2}, false},
+		{&Integer{Value: 1}, &String{Value: "1"}, false},
+		{&String{Value: "a"}, &String{Value: "a"}, true},
+		{&Boolean{Value: true}, &Boolean{Value: true}, true},
+		{&Boolean{Value: true}, &Integer{Value: 1}, false},
+		{&Null{}, &Null{}, true},
+		{&Null{}, &Boolean{Value: false}, false},
+		{
+			&Array{Elements: []Object{&Integer{Value: 1}, &Array{Elements: []Object{&String{Value: "a"}}}}},
+			&Array{Elements: []Object{&Integer{Value: 1}, &Array{Elements: []Object{&String{Value: "a"}}}}},
+			true,
+		},
+		{
+			&Array{Elements: []Object{&Integer{Value: 1}}},
+			&Array{Elements: []Object{&Integer{Value: 1}, &Integer{Value: 2}}},
+			false,
+		},
+		{fn, fn, true},
+		{fn, &Function{}, false},
+		{&Error{Message: "boom"}, &Error{Message: "boom"}, true},
+	}
+
+	for i, tt := range tests {
+		if got := tt.left.Equals(tt.right); got != tt.expected {
+			t.Errorf("tests[%d] - %s.Equals(%s) wrong. want=%t, got=%t",
+				i, tt.left.Inspect(), tt.right.Inspect(), tt.expected, got)
+		}
+		if got := tt.right.Equals(tt.left); got != tt.expected {
+			t.Errorf("tests[%d] - Equals is not symmetric for %s and %s", i, tt.left.Inspect(), tt.right.Inspect())
+		}
+	}
+}
+
+func TestHashEquals(t *testing.T) {
+	one := &String{Value: "one"}
+	two := &String{Value: "two"}
+
+	h1 := NewHash()
+	h1.Set(one, &Integer{Value: 1})
+	h1.Set(two, &Integer{Value: 2})
+	h2 := NewHash()
+	h2.Set(two, &Integer{Value: 2})
+	h2.Set(one, &Integer{Value: 1})
+	h3 := NewHash()
+	h3.Set(one, &Integer{Value: 1})
+
+	if !h1.Equals(h2) {
+		t.Errorf("hashes with the same pairs should be equal")
+	}
+	if h1.Equals(h3) || h3.Equals(h1) {
+		t.Errorf("hashes with different pairs should not be equal")
+	}
+}
+
+func TestHashKeys(t *testing.T) {
+	if (&Boolean{Value: true}).HashKey() == (&Integer{Value: 1}).HashKey() {
+		t.Errorf("booleans and integers have the same hash key")
+	}
+
+	arr1 := &Array{Elements: []Object{&Integer{Value: 1}, &String{Value: "a"}}}
+	arr2 := &Array{Elements: []Object{&Integer{Value: 1}, &String{Value: "a"}}}
+	if arr1.HashKey() != arr2.HashKey() {
+		t.Errorf("equal arrays have different hash keys")
+	}
+
+	h1 := NewHash()
+	h1.Set(&String{Value: "a"}, arr1)
+	h1.Set(&String{Value: "b"}, &Boolean{Value: true})
+	h2 := NewHash()
+	h2.Set(&String{Value: "b"}, &Boolean{Value: true})
+	h2.Set(&String{Value: "a"}, arr2)
+	if h1.HashKey() != h2.HashKey() {
+		t.Errorf("equal hashes have different hash keys")
+	}
+
+	if IsHashable(&Array{Elements: []Object{&Function{}}}) {
+		t.Errorf("array holding a function should not be hashable")
+	}
+}
+
+func TestHashCollisions(t *testing.T) {
+	h := NewHash()
+	// simulate a collision by placing a different key in the bucket of 1
+	h.pairs = []HashPair{{Key: &Integer{Value: 2}, Value: &String{Value: "two"}}}
+	h.buckets[HashKey{Type: INTEGER, Value: 1}] = []int{0}
+
+	if value, ok := h.Get(&Integer{Value: 1}); ok {
+		t.Fatalf("colliding key should not be found. got=%s", value.Inspect())
+	}
+
+	h.Set(&Integer{Value: 1}, &String{Value: "one"})
+	if h.Len() != 2 {
+		t.Fatalf("hash has wrong length. got=%d", h.Len())
+	}
+
+	value, ok := h.Get(&Integer{Value: 1})
+	if !ok || value.Inspect() != "one" {
+		t.Errorf("wrong value for colliding key. got=%v", value)
+	}
+}
+
+func TestHashInsertionOrder(t *testing.T) {
+	h := NewHash()
+	for _, key := range []string{"c", "a", "b", "e", "d"} {
+		h.Set(&String{Value: key}, &Integer{Value: 1})
+	}
+	h.Set(&String{Value: "a"}, &Integer{Value: 2})
+
+	expected := "{c: 1, a: 2, b: 1, e: 1, d: 1}"
+	if h.Inspect() != expected {
+		t.Errorf("wrong Inspect output. want=%q, got=%q", expected, h.Inspect())
+	}
+}
+
+func TestErrorTraceback(t *testing.T) {
+	err := &Error{
+		Message: "boom",
+		Stack: []StackFrame{
+			{Function: "inner", Line: 2, Column: 10},
+			{Function: "count", Line: 3, Column: 5},
+			{Function: "count", Line: 3, Column: 5},
+			{Function: "count", Line: 3, Column: 5},
+			{Function: "count", Line: 5, Column: 1},
+			{Function: "<anonymous>"},
+		},
+	}
+
+	expected := `ERROR: boom
+  at inner (line 2, column 10)
+  at count (line 3, column 5)
+  ... repeated 2 more times
+  at count (line 5, column 1)
+  at <anonymous>`
+	if err.Traceback() != expected {
+		t.Errorf("wrong traceback. want=%q, got=%q", expected, err.Traceback())
+	}
+
+	if (&Error{Message: "boom"}).Traceback() != "ERROR: boom" {
+		t.Errorf("an error without a stack should print as Inspect")
+	}
+}
+
+func TestEqualsCyclic(t *testing.T) {
+	a := &Array{}
+	a.Elements = []Object{a}
+	b := &Array{}
+	b.Elements = []Object{b}
+
+	h := NewHash()
+	h.Set(&String{Value: "self"}, h)
+
+	tests := []struct {
+		left     Object
+		right    Object
+		expected bool
+	}{
+		{a, a, true},
+		{a, &Array{Elements: []Object{a}}, true},
+		{a, b, false},
+		{a, &Array{Elements: []Object{&Integer{Value: 1}}}, false},
+		{h, h, true},
+		{h, NewHash(), false},
+	}
+
+	for i, tt := range tests {
+		if got := tt.left.Equals(tt.right); got != tt.expected {
+			t.Errorf("tests[%d] - Equals wrong. want=%t, got=%t", i, tt.expected, got)
+		}
+	}
+}
+
+func TestIsHashableCyclic(t *testing.T) {
+	a := &Array{}
+	a.Elements = []Object{a}
+	h := NewHash()
+	h.Set(&String{Value: "self"}, h)
+	shared := &Array{Elements: []Object{&Integer{Value: 1}}}
+
+	tests := []struct {
+		obj      Object
+		expected bool
+	}{
+		{a, false},
+		{&Array{Elements: []Object{a}}, false},
+		{h, false},
+		{&Array{Elements: []Object{shared, shared}}, true},
+	}
+
+	for i, tt := range tests {
+		if got := IsHashable(tt.obj); got != tt.expected {
+			t.Errorf("tests[%d] - IsHashable wrong. want=%t, got=%t", i, tt.expected, got)
+		}
+	}
+}
+
+func TestEnvironmentNestedLookup(t *testing.T) {
+	global := NewEnvironment()
+	global.Store("x", &Integer{Value: 1})
+
+	env := global
+	for i := 0; i < 3; i++ {
+		env = NewEnclosedEnvironment(env)
+	}
+
+	obj, ok := env.Get("x")
+	if !ok || !obj.Equals(&Integer{Value: 1}) {
+		t.Errorf("x should be found three scopes up. got=%v, found=%t", obj, ok)
+	}
+	if _, ok := env.Get("y"); ok {
+		t.Errorf("y should not be found")
+	}
+}
@@ -12,6 +12,7 @@ import (
 const (
 	_ int = iota
 	LOWEST
+	TERNARY
 	EQUALS
 	LESSGREATER
 	SUM
@@ -22,6 +23,7 @@ const (
 )
 
 var precedence = map[token.TokenType]int{
+	token.QUESTION: TERNARY,
 	token.EQ:       EQUALS,
 	token.NOT_EQ:   EQUALS,
 	token.LT:       LESSGREATER,
@@ -45,6 +47,10 @@ type Parser struct {
 	peekToken    token.Token
 	errors       []string
 
+	// blockDepth counts the block statements being parsed, export is only
+	// allowed outside of them
+	blockDepth int
+
 	prefixParseFns map[token.TokenType]prefixParseFn
 	infixParseFns  map[token.TokenType]infixParseFn
 }
@@ -71,6 +77,9 @@ func NewParser(l *lexer.Lexer) *Parser {
 	p.registerPrefix(token.STRING, p.parseStringLiteralExpression)
 	p.registerPrefix(token.LBRACKET, p.parseArray)
 	p.registerPrefix(token.LBRACE, p.parseHashLiteral)
+	p.registerPrefix(token.MATCH, p.parseMatchExpression)
+	p.registerPrefix(token.TRY, p.parseTryExpression)
+	p.registerPrefix(token.IMPORT, p.parseImportExpression)
 
 	p.infixParseFns = make(map[token.TokenType]infixParseFn)
 	p.registerInfix(token.PLUS, p.parseInfixExpression)
@@ -83,6 +92,7 @@ func NewParser(l *lexer.Lexer) *Parser {
 	p.registerInfix(token.GT, p.parseInfixExpression)
 	p.registerInfix(token.LPAREN, p.parseCallExpression)
 	p.registerInfix(token.LBRACKET, p.parseIndexExpression)
+	p.registerInfix(token.QUESTION, p.parseConditionalExpression)
 
 	return p
 }
@@ -148,6 +158,10 @@ func (p *Parser) parseStatement() ast.Statement {
 		return p.parseLetStatement()
 	case token.RETURN:
 		return p.parseReturnStatement()
+	case token.THROW:
+		return p.parseThrowStatement()
+	case token.EXPORT:
+		return p.parseExportStatement()
 	default:
 		return p.parseExpressionStatement()
 	}
@@ -236,17 +250,23 @@ func (p *Parser) parseLetStatement() *ast.LetStatement {
 		},
 	}
 
-	if !p.expectPeek(token.IDENT) {
-		return nil
+	if p.peekToken.Type == token.LBRACKET || p.peekToken.Type == token.LBRACE {
+		p.nextToken()
+		letStatement.Pattern = p.parsePattern(false)
+		if letStatement.Pattern == nil {
+			return nil
+		}
+	} else {
+		if !p.expectPeek(token.IDENT) {
+			return nil
+		}
+		letStatement.Name = p.parseIdentifier()
 	}
 
-	identifier := p.parseIdentifier()
 	if !p.expectPeek(token.ASSIGN) {
 		return nil
 	}
 
-	letStatement.Name = identifier
-
 	p.nextToken()
 
 	letStatement.Value = p.parseExpression(LOWEST)
@@ -258,6 +278,177 @@ func (p *Parser) parseLetStatement() *ast.LetStatement {
 	return letStatement
 }
 
+// parsePattern parses the target of a binding starting at the current token.
+// Refutable patterns, used by match arms, may also contain literals.
+func (p *Parser) parsePattern(refutable bool) ast.Pattern {
+	switch p.currentToken.Type {
+	case token.IDENT:
+		return p.parseIdentifier()
+	case token.LBRACKET:
+		return p.parseArrayPattern(refutable)
+	case token.LBRACE:
+		return p.parseHashPattern(refutable)
+	case token.INT, token.STRING, token.TRUE, token.FALSE, token.MINUS:
+		if refutable {
+			return p.parseLiteralPattern()
+		}
+	}
+
+	p.errors = append(p.errors, fmt.Sprintf("expected pattern, got %s instead", p.currentToken.Type))
+	return nil
+}
+
+func (p *Parser) parseLiteralPattern() ast.Pattern {
+	pattern := &ast.LiteralPattern{Token: p.currentToken}
+
+	switch p.currentToken.Type {
+	case token.MINUS:
+		if !p.expectPeek(token.INT) {
+			return nil
+		}
+		pattern.Value = &ast.PrefixExpression{Token: pattern.Token, Operator: "-", Right: p.parseIntegerLiteral()}
+	case token.STRING:
+		pattern.Value = p.parseStringLiteralExpression()
+	case token.TRUE, token.FALSE:
+		pattern.Value = p.parseBoolean()
+	default:
+		pattern.Value = p.parseIntegerLiteral()
+	}
+
+	return pattern
+}
+
+// parsePatternRest parses what follows the ... closing an array or hash
+// pattern, an optional name for the remaining elements
+func (p *Parser) parsePatternRest() *ast.Identifier {
+	if p.peekToken.Type != token.IDENT {
+		return nil
+	}
+
+	p.nextToken()
+	return p.parseIdentifier()
+}
+
+func (p *Parser) parseArrayPattern(refutable bool) ast.Pattern {
+	pattern := &ast.ArrayPattern{Token: p.currentToken, Elements: []ast.Pattern{}}
+
+	for p.peekToken.Type != token.RBRACKET {
+		p.nextToken()
+
+		if p.currentToken.Type == token.ELLIPSIS {
+			pattern.Open = true
+			pattern.Rest = p.parsePatternRest()
+			break
+		}
+
+		element := p.parsePattern(refutable)
+		if element == nil {
+			return nil
+		}
+		pattern.Elements = append(pattern.Elements, element)
+
+		if p.peekToken.Type != token.COMMA {
+			break
+		}
+		p.nextToken()
+	}
+
+	if !p.expectPeek(token.RBRACKET) {
+		return nil
+	}
+	return pattern
+}
+
+func (p *Parser) parseHashPattern(refutable bool) ast.Pattern {
+	pattern := &ast.HashPattern{Token: p.currentToken, Pairs: []ast.HashPatternPair{}}
+
+	for p.peekToken.Type != token.RBRACE {
+		p.nextToken()
+
+		if p.currentToken.Type == token.ELLIPSIS {
+			pattern.Open = true
+			pattern.Rest = p.parsePatternRest()
+			break
+		}
+
+		if p.currentToken.Type != token.IDENT && p.currentToken.Type != token.STRING {
+			p.errors = append(p.errors, fmt.Sprintf("expected hash pattern key, got %s instead", p.currentToken.Type))
+			return nil
+		}
+
+		// {name} is short for {name: name}
+		pair := ast.HashPatternPair{Key: p.currentToken.Literal}
+		if p.peekToken.Type == token.COLON {
+			p.nextToken()
+			p.nextToken()
+			pair.Value = p.parsePattern(refutable)
+			if pair.Value == nil {
+				return nil
+			}
+		} else if p.currentToken.Type == token.IDENT {
+			pair.Value = p.parseIdentifier()
+		} else {
+			p.errors = append(p.errors, fmt.Sprintf("expected : after hash pattern key %q", pair.Key))
+			return nil
+		}
+		pattern.Pairs = append(pattern.Pairs, pair)
+
+		if p.peekToken.Type != token.COMMA {
+			break
+		}
+		p.nextToken()
+	}
+
+	if !p.expectPeek(token.RBRACE) {
+		return nil
+	}
+	return pattern
+}
+
+func (p *Parser) parseMatchExpression() ast.Expression {
+	expression := &ast.MatchExpression{Token: p.currentToken, Arms: []*ast.MatchArm{}}
+
+	p.nextToken()
+	expression.Value = p.parseExpression(LOWEST)
+
+	if !p.expectPeek(token.LBRACE) {
+		return nil
+	}
+
+	for p.peekToken.Type != token.RBRACE {
+		p.nextToken()
+
+		arm := &ast.MatchArm{Pattern: p.parsePattern(true)}
+		if arm.Pattern == nil {
+			return nil
+		}
+
+		if p.peekToken.Type == token.IF {
+			p.nextToken()
+			p.nextToken()
+			arm.Guard = p.parseExpression(LOWEST)
+		}
+
+		if !p.expectPeek(token.ARROW) {
+			return nil
+		}
+
+		p.nextToken()
+		arm.Body = p.parseExpression(LOWEST)
+		expression.Arms = append(expression.Arms, arm)
+
+		if p.peekToken.Type != token.COMMA {
+			break
+		}
+		p.nextToken()
+	}
+
+	if !p.expectPeek(token.RBRACE) {
+		return nil
+	}
+	return expression
+}
+
 func (p *Parser) parseReturnStatement() *ast.ReturnStatement {
 	statement := &ast.ReturnStatement{
 		Token: p.currentToken,
@@ -274,6 +465,60 @@ func (p *Parser) parseReturnStatement() *ast.ReturnStatement {
 	return statement
 }
 
+func (p *Parser) parseThrowStatement() *ast.ThrowStatement {
+	statement := &ast.ThrowStatement{
+		Token: p.currentToken,
+	}
+
+	p.nextToken()
+
+	statement.Value = p.parseExpression(LOWEST)
+	if statement.Value == nil {
+		return nil
+	}
+
+	if p.peekToken.Type == token.SEMICOLON {
+		p.nextToken()
+	}
+
+	return statement
+}
+
+func (p *Parser) parseExportStatement() ast.Statement {
+	statement := &ast.ExportStatement{
+		Token: p.currentToken,
+	}
+
+	if !p.expectPeek(token.LET) {
+		return nil
+	}
+
+	statement.Statement = p.parseLetStatement()
+	if statement.Statement == nil {
+		return nil
+	}
+
+	if p.blockDepth > 0 {
+		p.errors = append(p.errors, "export is only allowed at the top level of a module")
+		return nil
+	}
+
+	return statement
+}
+
+func (p *Parser) parseImportExpression() ast.Expression {
+	expression := &ast.ImportExpression{
+		Token: p.currentToken,
+	}
+
+	if !p.expectPeek(token.STRING) {
+		return nil
+	}
+
+	expression.Path = p.currentToken.Literal
+	return expression
+}
+
 func (p *Parser) expectPeek(t token.TokenType) bool {
 	if p.peekToken.Type != t {
 		p.addError(t)
@@ -345,6 +590,22 @@ func (p *Parser) parseIfExpression() ast.Expression {
 		// skip the else token
 		p.nextToken()
 
+		// `else if` is sugar for an else block holding a single nested if
+		if p.peekToken.Type == token.IF {
+			p.nextToken()
+
+			alternative := &ast.BlockStatement{Token: p.currentToken}
+			nested := &ast.ExpressionStatement{Token: p.currentToken}
+			nested.Expression = p.parseIfExpression()
+			if nested.Expression == nil {
+				return nil
+			}
+
+			alternative.Statements = []ast.Statement{nested}
+			exp.Alternative = alternative
+			return &exp
+		}
+
 		if !p.expectPeek(token.LBRACE) {
 			return nil
 		}
@@ -355,6 +616,71 @@ func (p *Parser) parseIfExpression() ast.Expression {
 	return &exp
 }
 
+func (p *Parser) parseConditionalExpression(condition ast.Expression) ast.Expression {
+	exp := &ast.ConditionalExpression{
+		Token:     p.currentToken,
+		Condition: condition,
+	}
+
+	p.nextToken()
+	exp.Consequence = p.parseExpression(LOWEST)
+
+	if !p.expectPeek(token.COLON) {
+		return nil
+	}
+
+	p.nextToken()
+	// parsing the alternative with LOWEST makes the operator right associative
+	exp.Alternative = p.parseExpression(LOWEST)
+
+	return exp
+}
+
+func (p *Parser) parseTryExpression() ast.Expression {
+	exp := &ast.TryExpression{Token: p.currentToken}
+
+	if !p.expectPeek(token.LBRACE) {
+		return nil
+	}
+	exp.Block = p.parseBlockStatement()
+
+	if p.peekToken.Type == token.CATCH {
+		p.nextToken()
+
+		if p.peekToken.Type == token.LPAREN {
+			p.nextToken()
+			if !p.expectPeek(token.IDENT) {
+				return nil
+			}
+			exp.CatchParam = p.parseIdentifier()
+
+			if !p.expectPeek(token.RPAREN) {
+				return nil
+			}
+		}
+
+		if !p.expectPeek(token.LBRACE) {
+			return nil
+		}
+		exp.Catch = p.parseBlockStatement()
+	}
+
+	if p.peekToken.Type == token.FINALLY {
+		p.nextToken()
+
+		if !p.expectPeek(token.LBRACE) {
+			return nil
+		}
+		exp.Finally = p.parseBlockStatement()
+	}
+
+	if exp.Catch == nil && exp.Finally == nil {
+		p.errors = append(p.errors, "expected catch or finally after try block")
+		return nil
+	}
+	return exp
+}
+
 func (p *Parser) parseBlockStatement() *ast.BlockStatement {
 	blocks := &ast.BlockStatement{
 		Token: p.currentToken,
@@ -362,6 +688,9 @@ func (p *Parser) parseBlockStatement() *ast.BlockStatement {
 
 	p.nextToken()
 
+	p.blockDepth++
+	defer func() { p.blockDepth-- }()
+
 	for p.currentToken.Type != token.EOF && p.currentToken.Type != token.RBRACE {
 		s := p.parseStatement()
 		if s != nil {
@@ -396,23 +725,66 @@ func (p *Parser) parseFunctionExpression() ast.Expression {
 	return expression
 }
 
-func (p *Parser) parseFunctionParameters() []*ast.Identifier {
-	res := []*ast.Identifier{}
+func (p *Parser) parseFunctionParameters() []*ast.Parameter {
+	res := []*ast.Parameter{}
 
 	for p.currentToken.Type != token.RPAREN {
-		if p.currentToken.Type != token.COMMA {
-			res = append(res, &ast.Identifier{
-				Token: p.currentToken,
-				Value: p.currentToken.Literal,
-			})
+		if p.currentToken.Type == token.COMMA {
+			p.nextToken()
+			continue
 		}
 
+		if len(res) > 0 && res[len(res)-1].Rest {
+			p.errors = append(p.errors, fmt.Sprintf("rest parameter %s must be the last parameter", res[len(res)-1]))
+			return nil
+		}
+
+		param := p.parseFunctionParameter()
+		if param == nil {
+			return nil
+		}
+		res = append(res, param)
+
 		p.nextToken()
 	}
 
 	return res
 }
 
+func (p *Parser) parseFunctionParameter() *ast.Parameter {
+	param := &ast.Parameter{}
+	if p.currentToken.Type == token.ELLIPSIS {
+		param.Rest = true
+		p.nextToken()
+	}
+
+	switch {
+	case !param.Rest && (p.currentToken.Type == token.LBRACKET || p.currentToken.Type == token.LBRACE):
+		param.Pattern = p.parsePattern(false)
+		if param.Pattern == nil {
+			return nil
+		}
+	case p.currentToken.Type == token.IDENT:
+		param.Name = &ast.Identifier{Token: p.currentToken, Value: p.currentToken.Literal}
+	default:
+		p.errors = append(p.errors, fmt.Sprintf("expected parameter name, got %s instead", p.currentToken.Type))
+		return nil
+	}
+
+	if p.peekToken.Type == token.ASSIGN {
+		if param.Rest {
+			p.errors = append(p.errors, fmt.Sprintf("rest parameter %s cannot have a default value", param))
+			return nil
+		}
+
+		p.nextToken()
+		p.nextToken()
+		param.Default = p.parseExpression(LOWEST)
+	}
+
+	return param
+}
+
 func (p *Parser) parseCallExpression(function ast.Expression) ast.Expression {
 	expression := ast.CallExpression{
 		Token:    p.currentToken,
@@ -432,12 +804,12 @@ func (p *Parser) parseCallArguments() []ast.Expression {
 
 	p.nextToken()
 
-	res = append(res, p.parseExpression(LOWEST))
+	res = append(res, p.parseCallArgument())
 
 	for p.peekToken.Type == token.COMMA {
 		p.nextToken()
 		p.nextToken()
-		res = append(res, p.parseExpression(LOWEST))
+		res = append(res, p.parseCallArgument())
 	}
 
 	if !p.expectPeek(token.RPAREN) {
@@ -447,16 +819,74 @@ func (p *Parser) parseCallArguments() []ast.Expression {
 	return res
 }
 
+// parseCallArgument parses an argument that may also be spread, ...xs, or
+// passed by name, b = 2
+func (p *Parser) parseCallArgument() ast.Expression {
+	switch {
+	case p.currentToken.Type == token.ELLIPSIS:
+		spread := &ast.SpreadExpression{Token: p.currentToken}
+		p.nextToken()
+		spread.Value = p.parseExpression(LOWEST)
+		return spread
+	case p.currentToken.Type == token.IDENT && p.peekToken.Type == token.ASSIGN:
+		named := &ast.NamedArgument{
+			Token: p.currentToken,
+			Name:  &ast.Identifier{Token: p.currentToken, Value: p.currentToken.Literal},
+		}
+		p.nextToken()
+		p.nextToken()
+		named.Value = p.parseExpression(LOWEST)
+		return named
+	default:
+		return p.parseExpression(LOWEST)
+	}
+}
+
 func (p *Parser) parseIndexExpression(left ast.Expression) ast.Expression {
 	res := &ast.IndexExpression{
 		Token: p.currentToken,
 		Left:  left,
 	}
 
+	if p.peekToken.Type == token.COLON {
+		return p.parseSliceExpression(res.Token, left, nil)
+	}
+
 	p.nextToken()
 
 	res.Index = p.parseExpression(LOWEST)
 
+	if p.peekToken.Type == token.COLON {
+		return p.parseSliceExpression(res.Token, left, res.Index)
+	}
+
+	if !p.expectPeek(token.RBRACKET) {
+		return nil
+	}
+
+	return res
+}
+
+// parseSliceExpression expects the peek token to be the colon that follows
+// the (possibly omitted) start of the slice
+func (p *Parser) parseSliceExpression(bracket token.Token, left ast.Expression, start ast.Expression) ast.Expression {
+	res := &ast.SliceExpression{
+		Token: bracket,
+		Left:  left,
+		Start: start,
+	}
+
+	// skip the colon
+	p.nextToken()
+
+	if p.peekToken.Type == token.RBRACKET {
+		p.nextToken()
+		return res
+	}
+
+	p.nextToken()
+	res.End = p.parseExpression(LOWEST)
+
 	if !p.expectPeek(token.RBRACKET) {
 		return nil
 	}
@@ -500,7 +930,7 @@ func (p *Parser) parseArray() ast.Expression {
 func (p *Parser) parseHashLiteral() ast.Expression {
 	hash := &ast.HashLiteral{
 		Token: p.currentToken,
-		Pairs: make(map[ast.Expression]ast.Expression),
+		Pairs: []ast.HashLiteralPair{},
 	}
 
 	p.nextToken()
@@ -520,7 +950,7 @@ func (p *Parser) parseHashLiteral() ast.Expression {
 		p.nextToken()
 
 		value := p.parseExpression(LOWEST)
-		hash.Pairs[key] = value
+		hash.Pairs = append(hash.Pairs, ast.HashLiteralPair{Key: key, Value: value})
 
 		p.nextToken()
 
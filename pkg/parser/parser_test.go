@@ -41,6 +41,67 @@ func TestLetStatements(t *testing.T) {
 	}
 }
 
+func TestDestructuringLetStatements(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"let [a, b] = xs;", "let [a, b] = xs;"},
+		{"let [a, ...rest] = xs;", "let [a, ...rest] = xs;"},
+		{"let [] = xs;", "let [] = xs;"},
+		{"let {name, age} = person;", "let {name, age} = person;"},
+		{`let {"first name": first, ...others} = person;`, "let {first name: first, ...others} = person;"},
+		{"let {address: {city}, tags: [tag]} = person;", "let {address: {city}, tags: [tag]} = person;"},
+		{"let f = fn([a, b], {c} = d) { a };", "let f = fn([a, b],{c} = d{a};"},
+	}
+
+	for _, tt := range tests {
+		p := NewParser(lexer.NewLexer(tt.input))
+		program := p.ParseProgram()
+		checkParserErrors(t, p)
+
+		if len(program.Statements) != 1 {
+			t.Fatalf("program.Statements does not contain 1 statements. got=%d", len(program.Statements))
+		}
+
+		if program.String() != tt.expected {
+			t.Errorf("wrong program. want=%q, got=%q", tt.expected, program.String())
+		}
+	}
+
+	program := NewParser(lexer.NewLexer("let [a, {b}] = xs;")).ParseProgram()
+	pattern, ok := program.Statements[0].(*ast.LetStatement).Pattern.(*ast.ArrayPattern)
+	if !ok {
+		t.Fatalf("pattern is not ast.ArrayPattern. got=%T", program.Statements[0].(*ast.LetStatement).Pattern)
+	}
+	testIdentifier(t, pattern.Elements[0].(*ast.Identifier), "a")
+	if hash, ok := pattern.Elements[1].(*ast.HashPattern); !ok || hash.Pairs[0].Key != "b" {
+		t.Errorf("element 1 is not the hash pattern {b}. got=%s", pattern.Elements[1])
+	}
+}
+
+func TestInvalidPatterns(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"let [1] = xs;", "expected pattern, got INT instead"},
+		{"let [...rest, a] = xs;", "expected next token to be ,, got ] instead"},
+		{"let {1: a} = xs;", "expected hash pattern key, got INT instead"},
+		{`let {"a"} = xs;`, `expected : after hash pattern key "a"`},
+		{"fn(...[a]) {}", "expected parameter name, got [ instead"},
+	}
+
+	for _, tt := range tests {
+		p := NewParser(lexer.NewLexer(tt.input))
+		p.ParseProgram()
+
+		if len(p.errors) == 0 || p.errors[0] != tt.expected {
+			t.Errorf("wrong parser errors for %q. want=%q, got=%q", tt.input, tt.expected, p.errors)
+		}
+	}
+}
+
 func TestReturnStatement(t *testing.T) {
 	tests := []struct {
 		input         string
@@ -317,6 +378,30 @@ func TestOperatorPrecedenceParsing(t *testing.T) {
 			"add(a * b[2], b[1], 2 * [1, 2][1])",
 			"add((a * (b[2])), (b[1]), (2 * ([1, 2][1])))",
 		},
+		{
+			"a < b ? a + 1 : b * 2",
+			"((a < b) ? (a + 1) : (b * 2))",
+		},
+		{
+			"a ? b : c ? d : e",
+			"(a ? b : (c ? d : e))",
+		},
+		{
+			"a[1:b + 1]",
+			"(a[1:(b + 1)])",
+		},
+		{
+			"a[:2][-1]",
+			"((a[:2])[(-1)])",
+		},
+		{
+			"a[x ? 1 : 2:]",
+			"(a[(x ? 1 : 2):])",
+		},
+		{
+			"add(a ? b : c, d)",
+			"add((a ? b : c), d)",
+		},
 	}
 
 	for _, tt := range tests {
@@ -550,6 +635,263 @@ func TestIfElseExpression(t *testing.T) {
 	}
 }
 
+func TestElseIfExpression(t *testing.T) {
+	input := `if (x < y) { x } else if (x > y) { y } else { z }`
+	l := lexer.NewLexer(input)
+	p := NewParser(l)
+	program := p.ParseProgram()
+
+	checkParserErrors(t, p)
+
+	if len(program.Statements) != 1 {
+		t.Fatalf("program.Body does not contain %d statements. got=%d\n", 1, len(program.Statements))
+	}
+
+	stmt, ok := program.Statements[0].(*ast.ExpressionStatement)
+	if !ok {
+		t.Fatalf("program.Statements[0] is not ast.ExpressionStatement. got=%T", program.Statements[0])
+	}
+
+	exp, ok := stmt.Expression.(*ast.IfExpression)
+	if !ok {
+		t.Fatalf("stmt.Expression is not ast.IfExpression. got=%T", stmt.Expression)
+	}
+
+	if exp.Alternative == nil || len(exp.Alternative.Statements) != 1 {
+		t.Fatalf("alternative is not 1 statement. got=%+v", exp.Alternative)
+	}
+
+	alternative, ok := exp.Alternative.Statements[0].(*ast.ExpressionStatement)
+	if !ok {
+		t.Fatalf("Statements[0] is not ast.ExpressionStatement. got=%T", exp.Alternative.Statements[0])
+	}
+
+	nested, ok := alternative.Expression.(*ast.IfExpression)
+	if !ok {
+		t.Fatalf("alternative is not ast.IfExpression. got=%T", alternative.Expression)
+	}
+
+	if !testInfixExpression(t, nested.Condition, "x", ">", "y") {
+		return
+	}
+
+	consequence := nested.Consequence.Statements[0].(*ast.ExpressionStatement)
+	if !testIdentifier(t, consequence.Expression, "y") {
+		return
+	}
+
+	if nested.Alternative == nil {
+		t.Fatalf("nested.Alternative is nil")
+	}
+
+	last := nested.Alternative.Statements[0].(*ast.ExpressionStatement)
+	testIdentifier(t, last.Expression, "z")
+}
+
+func TestConditionalExpression(t *testing.T) {
+	input := `x < y ? x : y`
+	l := lexer.NewLexer(input)
+	p := NewParser(l)
+	program := p.ParseProgram()
+
+	checkParserErrors(t, p)
+
+	stmt := program.Statements[0].(*ast.ExpressionStatement)
+	exp, ok := stmt.Expression.(*ast.ConditionalExpression)
+	if !ok {
+		t.Fatalf("stmt.Expression is not ast.ConditionalExpression. got=%T", stmt.Expression)
+	}
+
+	if !testInfixExpression(t, exp.Condition, "x", "<", "y") {
+		return
+	}
+
+	testIdentifier(t, exp.Consequence, "x")
+	testIdentifier(t, exp.Alternative, "y")
+}
+
+func TestMatchExpression(t *testing.T) {
+	input := `match (value) {
+  0 => "zero",
+  -1 => "minus one",
+  [x, y] if x > y => x,
+  [first, ...] => first,
+  {"type": "point", "x": x, ...rest} => rest,
+  true => 1,
+  _ => "other",
+}`
+
+	p := NewParser(lexer.NewLexer(input))
+	program := p.ParseProgram()
+	checkParserErrors(t, p)
+
+	stmt := program.Statements[0].(*ast.ExpressionStatement)
+	match, ok := stmt.Expression.(*ast.MatchExpression)
+	if !ok {
+		t.Fatalf("stmt.Expression is not ast.MatchExpression. got=%T", stmt.Expression)
+	}
+
+	testIdentifier(t, match.Value, "value")
+
+	expected := []string{
+		"0 => zero",
+		"(-1) => minus one",
+		"[x, y] if (x > y) => x",
+		"[first, ...] => first",
+		`{type: "point", x, ...rest} => rest`,
+		"true => 1",
+		"_ => other",
+	}
+	if len(match.Arms) != len(expected) {
+		t.Fatalf("wrong number of arms. want=%d, got=%d", len(expected), len(match.Arms))
+	}
+
+	for i, arm := range match.Arms {
+		if arm.String() != expected[i] {
+			t.Errorf("arm %d wrong. want=%q, got=%q", i, expected[i], arm.String())
+		}
+	}
+
+	if _, ok := match.Arms[0].Pattern.(*ast.LiteralPattern); !ok {
+		t.Errorf("arm 0 pattern is not ast.LiteralPattern. got=%T", match.Arms[0].Pattern)
+	}
+	testInfixExpression(t, match.Arms[2].Guard, "x", ">", "y")
+}
+
+func TestInvalidMatchExpressions(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"match (x) { 1 2 }", "expected next token to be INT, got => instead"},
+		{"match (x) { + => 1 }", "expected pattern, got + instead"},
+		{"match (x) { 1 => 2 3 }", "expected next token to be INT, got } instead"},
+	}
+
+	for _, tt := range tests {
+		p := NewParser(lexer.NewLexer(tt.input))
+		p.ParseProgram()
+
+		if len(p.errors) == 0 || p.errors[0] != tt.expected {
+			t.Errorf("wrong parser errors for %q. want=%q, got=%q", tt.input, tt.expected, p.errors)
+		}
+	}
+}
+
+func TestTryExpression(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"try { risky() } catch (e) { e }", "try {risky()} catch (e) {e}"},
+		{"try { risky() } catch { 0 }", "try {risky()} catch {0}"},
+		{"try { risky() } finally { cleanup() }", "try {risky()} finally {cleanup()}"},
+		{`try { throw "boom"; } catch (e) { 1 } finally { 2 }`, "try {throw boom;} catch (e) {1} finally {2}"},
+	}
+
+	for _, tt := range tests {
+		p := NewParser(lexer.NewLexer(tt.input))
+		program := p.ParseProgram()
+		checkParserErrors(t, p)
+
+		stmt := program.Statements[0].(*ast.ExpressionStatement)
+		if _, ok := stmt.Expression.(*ast.TryExpression); !ok {
+			t.Fatalf("stmt.Expression is not ast.TryExpression. got=%T", stmt.Expression)
+		}
+
+		if program.String() != tt.expected {
+			t.Errorf("wrong program. want=%q, got=%q", tt.expected, program.String())
+		}
+	}
+
+	errorTests := []struct {
+		input    string
+		expected string
+	}{
+		{"try { 1 }", "expected catch or finally after try block"},
+		{"try { 1 } catch (1) { 2 }", "expected next token to be INT, got IDENT instead"},
+	}
+
+	for _, tt := range errorTests {
+		p := NewParser(lexer.NewLexer(tt.input))
+		p.ParseProgram()
+
+		if len(p.errors) == 0 || p.errors[0] != tt.expected {
+			t.Errorf("wrong parser errors for %q. want=%q, got=%q", tt.input, tt.expected, p.errors)
+		}
+	}
+}
+
+func TestThrowStatement(t *testing.T) {
+	p := NewParser(lexer.NewLexer(`throw x + 1;`))
+	program := p.ParseProgram()
+	checkParserErrors(t, p)
+
+	stmt, ok := program.Statements[0].(*ast.ThrowStatement)
+	if !ok {
+		t.Fatalf("statement is not ast.ThrowStatement. got=%T", program.Statements[0])
+	}
+	testInfixExpression(t, stmt.Value, "x", "+", 1)
+}
+
+func TestImportAndExport(t *testing.T) {
+	input := `let math = import "lib/math";
+export let answer = 42;
+export let {pi, e} = math;`
+
+	p := NewParser(lexer.NewLexer(input))
+	program := p.ParseProgram()
+	checkParserErrors(t, p)
+
+	if len(program.Statements) != 3 {
+		t.Fatalf("program.Statements does not contain 3 statements. got=%d", len(program.Statements))
+	}
+
+	let := program.Statements[0].(*ast.LetStatement)
+	imp, ok := let.Value.(*ast.ImportExpression)
+	if !ok {
+		t.Fatalf("let.Value is not ast.ImportExpression. got=%T", let.Value)
+	}
+	if imp.Path != "lib/math" {
+		t.Errorf("wrong import path. got=%q", imp.Path)
+	}
+
+	for i, name := range []string{"answer", ""} {
+		export, ok := program.Statements[i+1].(*ast.ExportStatement)
+		if !ok {
+			t.Fatalf("statement %d is not ast.ExportStatement. got=%T", i+1, program.Statements[i+1])
+		}
+		if name != "" && export.Statement.Name.Value != name {
+			t.Errorf("wrong exported name. want=%s, got=%s", name, export.Statement.Name.Value)
+		}
+	}
+
+	expected := `let math = import "lib/math";export let answer = 42;export let {pi, e} = math;`
+	if program.String() != expected {
+		t.Errorf("wrong program. want=%q, got=%q", expected, program.String())
+	}
+
+	errorTests := []struct {
+		input    string
+		expected string
+	}{
+		{`import math`, "expected next token to be IDENT, got STRING instead"},
+		{`export fn() {}`, "expected next token to be FUNCTION, got LET instead"},
+		{`if (true) { export let a = 1 }`, "export is only allowed at the top level of a module"},
+		{`let f = fn() { export let a = 1; }`, "export is only allowed at the top level of a module"},
+		{`try { export let a = 1 } catch { 0 }`, "export is only allowed at the top level of a module"},
+	}
+
+	for _, tt := range errorTests {
+		p := NewParser(lexer.NewLexer(tt.input))
+		p.ParseProgram()
+
+		if len(p.errors) == 0 || p.errors[0] != tt.expected {
+			t.Errorf("wrong parser errors for %q. want=%q, got=%q", tt.input, tt.expected, p.errors)
+		}
+	}
+}
+
 func TestFunctionLiteralParsing(t *testing.T) {
 	input := `fn(x, y) { x + y; }`
 	l := lexer.NewLexer(input)
@@ -576,8 +918,8 @@ func TestFunctionLiteralParsing(t *testing.T) {
 		t.Fatalf("function literal parameters wrong. want 2, got=%d\n", len(function.Parameters))
 	}
 
-	testLiteralExpression(t, function.Parameters[0], "x")
-	testLiteralExpression(t, function.Parameters[1], "y")
+	testLiteralExpression(t, function.Parameters[0].Name, "x")
+	testLiteralExpression(t, function.Parameters[1].Name, "y")
 
 	if len(function.Body.Statements) != 1 {
 		t.Fatalf("function.Body.Statements has not 1 statements. got=%d\n", len(function.Body.Statements))
@@ -616,9 +958,96 @@ func TestFunctionParameterParsing(t *testing.T) {
 		}
 
 		for i, ident := range tt.expectedParams {
-			testLiteralExpression(t, function.Parameters[i], ident)
+			testLiteralExpression(t, function.Parameters[i].Name, ident)
+		}
+	}
+}
+
+func TestDefaultAndRestParameterParsing(t *testing.T) {
+	input := "fn(a, b = a * 2, ...rest) { rest };"
+	l := lexer.NewLexer(input)
+	p := NewParser(l)
+	program := p.ParseProgram()
+
+	checkParserErrors(t, p)
+
+	stmt := program.Statements[0].(*ast.ExpressionStatement)
+	function := stmt.Expression.(*ast.FunctionLiteral)
+
+	expected := []string{"a", "b = (a * 2)", "...rest"}
+	if len(function.Parameters) != len(expected) {
+		t.Fatalf("length parameters wrong. want %d, got=%d\n", len(expected), len(function.Parameters))
+	}
+
+	for i, param := range function.Parameters {
+		if param.String() != expected[i] {
+			t.Errorf("parameter %d wrong. want=%q, got=%q", i, expected[i], param.String())
 		}
 	}
+
+	if function.Parameters[0].Default != nil || function.Parameters[0].Rest {
+		t.Errorf("parameter a should be a plain parameter")
+	}
+	testInfixExpression(t, function.Parameters[1].Default, "a", "*", 2)
+	if !function.Parameters[2].Rest {
+		t.Errorf("parameter rest should be a rest parameter")
+	}
+}
+
+func TestInvalidParameterParsing(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"fn(...rest, a) {}", "rest parameter ...rest must be the last parameter"},
+		{"fn(...rest = 1) {}", "rest parameter ...rest cannot have a default value"},
+		{"fn(1) {}", "expected parameter name, got INT instead"},
+		{"fn(a", "expected parameter name, got EOF instead"},
+	}
+
+	for _, tt := range tests {
+		p := NewParser(lexer.NewLexer(tt.input))
+		p.ParseProgram()
+
+		if len(p.errors) == 0 || p.errors[0] != tt.expected {
+			t.Errorf("wrong parser errors for %q. want=%q, got=%q", tt.input, tt.expected, p.errors)
+		}
+	}
+}
+
+func TestSpreadAndNamedArgumentParsing(t *testing.T) {
+	input := "f(1, ...xs, b = 2 * 3, c = g(...ys));"
+	l := lexer.NewLexer(input)
+	p := NewParser(l)
+	program := p.ParseProgram()
+
+	checkParserErrors(t, p)
+
+	stmt := program.Statements[0].(*ast.ExpressionStatement)
+	call := stmt.Expression.(*ast.CallExpression)
+
+	if len(call.Arguments) != 4 {
+		t.Fatalf("wrong length of arguments. got=%d", len(call.Arguments))
+	}
+
+	testLiteralExpression(t, call.Arguments[0], 1)
+
+	spread, ok := call.Arguments[1].(*ast.SpreadExpression)
+	if !ok {
+		t.Fatalf("argument 1 is not ast.SpreadExpression. got=%T", call.Arguments[1])
+	}
+	testIdentifier(t, spread.Value, "xs")
+
+	named, ok := call.Arguments[2].(*ast.NamedArgument)
+	if !ok {
+		t.Fatalf("argument 2 is not ast.NamedArgument. got=%T", call.Arguments[2])
+	}
+	testIdentifier(t, named.Name, "b")
+	testInfixExpression(t, named.Value, 2, "*", 3)
+
+	if call.Arguments[3].String() != "c = g(...ys)" {
+		t.Errorf("argument 3 wrong. got=%q", call.Arguments[3].String())
+	}
 }
 
 func TestCallExpressionParsing(t *testing.T) {
@@ -723,6 +1152,50 @@ func TestParsingIndexExpressions(t *testing.T) {
 	}
 }
 
+func TestParsingSliceExpressions(t *testing.T) {
+	tests := []struct {
+		input         string
+		expectedStart interface{}
+		expectedEnd   interface{}
+	}{
+		{"myArray[1:3]", 1, 3},
+		{"myArray[:3]", nil, 3},
+		{"myArray[1:]", 1, nil},
+		{"myArray[:]", nil, nil},
+	}
+
+	for _, tt := range tests {
+		l := lexer.NewLexer(tt.input)
+		p := NewParser(l)
+		program := p.ParseProgram()
+
+		checkParserErrors(t, p)
+
+		stmt := program.Statements[0].(*ast.ExpressionStatement)
+		sliceExp, ok := stmt.Expression.(*ast.SliceExpression)
+		if !ok {
+			t.Fatalf("exp not *ast.SliceExpression. got=%T", stmt.Expression)
+		}
+
+		if !testIdentifier(t, sliceExp.Left, "myArray") {
+			return
+		}
+
+		for _, bound := range []struct {
+			exp      ast.Expression
+			expected interface{}
+		}{{sliceExp.Start, tt.expectedStart}, {sliceExp.End, tt.expectedEnd}} {
+			if bound.expected == nil {
+				if bound.exp != nil {
+					t.Errorf("expected omitted bound. got=%s", bound.exp)
+				}
+				continue
+			}
+			testLiteralExpression(t, bound.exp, bound.expected)
+		}
+	}
+}
+
 func TestParsingHashLiteralsStringKeys(t *testing.T) {
 	input := `{"one": 1, "two": 2, "three": 3}`
 	l := lexer.NewLexer(input)
@@ -744,13 +1217,13 @@ func TestParsingHashLiteralsStringKeys(t *testing.T) {
 		"two":   2,
 		"three": 3,
 	}
-	for key, value := range hash.Pairs {
-		literal, ok := key.(*ast.StringLiteral)
+	for _, pair := range hash.Pairs {
+		literal, ok := pair.Key.(*ast.StringLiteral)
 		if !ok {
-			t.Errorf("key is not ast.StringLiteral. got=%T", key)
+			t.Errorf("key is not ast.StringLiteral. got=%T", pair.Key)
 		}
 		expectedValue := expected[literal.String()]
-		testIntegerLiteral(t, value, expectedValue)
+		testIntegerLiteral(t, pair.Value, expectedValue)
 	}
 }
 
@@ -803,10 +1276,10 @@ func TestParsingHashLiteralsWithExpressions(t *testing.T) {
 		},
 	}
 
-	for key, value := range hash.Pairs {
-		literal, ok := key.(*ast.StringLiteral)
+	for _, pair := range hash.Pairs {
+		literal, ok := pair.Key.(*ast.StringLiteral)
 		if !ok {
-			t.Errorf("key is not ast.StringLiteral. got=%T", key)
+			t.Errorf("key is not ast.StringLiteral. got=%T", pair.Key)
 			continue
 		}
 
@@ -815,7 +1288,7 @@ func TestParsingHashLiteralsWithExpressions(t *testing.T) {
 			t.Errorf("No test function for key %q found", literal.String())
 			continue
 		}
-		testFunc(value)
+		testFunc(pair.Value)
 	}
 }
 
@@ -2,7 +2,6 @@ package repl
 
 import (
 	"bufio"
-	"fmt"
 	"io"
 
 	"github.com/AhmedThresh/not-even-a-compiler/pkg/eval"
@@ -14,17 +13,22 @@ import (
 const Prompt = ">>"
 
 func Start(in io.Reader, out io.Writer) {
-	scanner := bufio.NewScanner(in)
+	// read_line shares the reader, so lines are read without buffering
+	// ahead of the one being evaluated
+	reader := bufio.NewReader(in)
 	env := object.NewEnvironment()
+	env.Runtime().Stdin = reader
+	env.Runtime().Stdout = out
+	env.Runtime().Stderr = out
+	env.Runtime().ModulePaths = []string{"."}
 
 	for {
-		fmt.Print(Prompt)
-		scanned := scanner.Scan()
-		if !scanned {
+		io.WriteString(out, Prompt)
+		line, err := reader.ReadString('\n')
+		if err != nil && line == "" {
 			return
 		}
 
-		line := scanner.Text()
 		lexer := lexer.NewLexer(line)
 
 		parser := parser.NewParser(lexer)
@@ -36,6 +40,11 @@ func Start(in io.Reader, out io.Writer) {
 		}
 
 		evaluated := eval.Eval(program, env)
+		if errObj, ok := evaluated.(*object.Error); ok {
+			io.WriteString(out, errObj.Traceback())
+			io.WriteString(out, "\n")
+			continue
+		}
 		if evaluated != nil {
 			io.WriteString(out, evaluated.Inspect())
 			io.WriteString(out, "\n")
@@ -5,13 +5,20 @@ package token
 type TokenType string
 
 var keywords = map[string]TokenType{
-	"fn":     FUNCTION,
-	"let":    LET,
-	"return": RETURN,
-	"if":     IF,
-	"else":   ELSE,
-	"true":   TRUE,
-	"false":  FALSE,
+	"fn":      FUNCTION,
+	"let":     LET,
+	"return":  RETURN,
+	"if":      IF,
+	"else":    ELSE,
+	"true":    TRUE,
+	"false":   FALSE,
+	"match":   MATCH,
+	"throw":   THROW,
+	"try":     TRY,
+	"catch":   CATCH,
+	"finally": FINALLY,
+	"import":  IMPORT,
+	"export":  EXPORT,
 }
 
 const (
@@ -34,6 +41,9 @@ const (
 	GT       = ">"
 	EQ       = "=="
 	NOT_EQ   = "!="
+	QUESTION = "?"
+	ELLIPSIS = "..."
+	ARROW    = "=>"
 
 	// Delimiters
 	COMMA     = ","
@@ -54,6 +64,13 @@ const (
 	TRUE     = "TRUE"
 	FALSE    = "FALSE"
 	RETURN   = "RETURN"
+	MATCH    = "MATCH"
+	THROW    = "THROW"
+	TRY      = "TRY"
+	CATCH    = "CATCH"
+	FINALLY  = "FINALLY"
+	IMPORT   = "IMPORT"
+	EXPORT   = "EXPORT"
 )
 
 // Token defines the unit of the tokenization process
@@ -63,6 +80,10 @@ type Token struct {
 	Type TokenType
 	// Literal is the value of the token
 	Literal string
+	// Line and Column locate the first character of the token in the
+	// source, both start at 1
+	Line   int
+	Column int
 }
 
 // LookupIdent return the token type of a specific token
@@ -75,7 +96,7 @@ func LookupIdent(identifier string) TokenType {
 	return IDENT
 }
 
-func NewToken(tokenType TokenType, value byte) Token {
+func NewToken(tokenType TokenType, value rune) Token {
 	return Token{
 		Type:    tokenType,
 		Literal: string(value),